@@ -2,8 +2,14 @@ package espat
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/embeddedgo/espat/internal/esptest"
 )
 
 type writeCmdTest struct {
@@ -21,6 +27,8 @@ var writeCmdTests = []writeCmdTest{
 	{"+CIPSERVER=1,", []any{1234}, `AT+CIPSERVER=1,1234`, nil},
 	{"+SLEEP=", []any{'a'}, "", ErrArgType},
 	{"+CIPTCPOPT=", []any{-1, 0, 999}, `AT+CIPTCPOPT=-1,0,999`, nil},
+	{"+SYSMFG=2,", []any{"ns", "k", 8, int64(-1 << 40)}, `AT+SYSMFG=2,"ns","k",8,-1099511627776`, nil},
+	{"+SYSMFG=2,", []any{"ns", "k", 7, uint64(1 << 63)}, `AT+SYSMFG=2,"ns","k",7,9223372036854775808`, nil},
 }
 
 func TestWriteCmd(t *testing.T) {
@@ -54,3 +62,557 @@ func TestWriteCmd(t *testing.T) {
 		}
 	}
 }
+
+type statsSentTest struct {
+	args []any
+	ci   int
+	n    uint64
+}
+
+var statsSentTests = []statsSentTest{
+	{[]any{12}, 0, 12},
+	{[]any{3, 100}, 3, 100},
+	{[]any{7, "192.168.1.2", 1234}, 0, 7},
+	{[]any{2, 10, "192.168.1.2", 1234}, 2, 10},
+}
+
+func TestStatsSent(t *testing.T) {
+	for _, test := range statsSentTests {
+		var s stats
+		s.sent(test.args)
+		for ci, ls := range s.links {
+			n := uint64(0)
+			if ci == test.ci {
+				n = test.n
+			}
+			if ls.Sent != n {
+				t.Errorf("%+v: link %d: %d != %d", test.args, ci, ls.Sent, n)
+			}
+		}
+	}
+}
+
+type splitArgsTest struct {
+	in   string
+	args []string
+}
+
+var splitArgsTests = []splitArgsTest{
+	{``, nil},
+	{`1`, []string{"1"}},
+	{`1,,-2`, []string{"1", "", "-2"}},
+	{`"TCP","192.168.1.2",80`, []string{"TCP", "192.168.1.2", "80"}},
+	{`"a,b","c\"d\\",x`, []string{"a,b", `c"d\`, "x"}},
+	{`"ab`, []string{"ab"}},
+}
+
+func TestSplitArgs(t *testing.T) {
+	for _, test := range splitArgsTests {
+		args := SplitArgs(test.in)
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: %q != %q", test.in, args, test.args)
+		}
+	}
+}
+
+func TestParseLinkConn(t *testing.T) {
+	id, link, err := parseLinkConn(`0,2,"TCP",1,"192.168.1.2",53246,80`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &LinkInfo{"TCP", true, "192.168.1.2", 53246, 80}
+	if id != 2 || !reflect.DeepEqual(link, want) {
+		t.Errorf("%d %+v != 2 %+v", id, link, want)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	r, w := io.Pipe()
+	d := NewDevice("test", r, io.Discard)
+	lines := make(chan string, 2)
+	d.Handle("+SENSOR:", false, func(line string, payload []byte) {
+		lines <- line
+	})
+	d.Handle("+BLOB:", true, func(line string, payload []byte) {
+		lines <- line + "|" + string(payload)
+	})
+	io.WriteString(w, "+SENSOR:21.5,45\r\n+BLOB:1,6:a\r\nb\nc\r\n")
+	for _, want := range []string{"+SENSOR:21.5,45", "+BLOB:1,6|a\r\nb\nc"} {
+		if line := <-lines; line != want {
+			t.Errorf("%q != %q", line, want)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	s := "+CIPSTA:ip:\"192.168.1.2\"\n+CIPSTA:gateway:\"192.168.1.1\"\nAT version:2.2.0\n+ x\n"
+	want := []Line{
+		{"+CIPSTA", `ip:"192.168.1.2"`},
+		{"+CIPSTA", `gateway:"192.168.1.1"`},
+		{"", "AT version:2.2.0"},
+		{"", "+ x"},
+	}
+	lines := splitLines(s)
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("%q != %q", lines, want)
+	}
+	resp := &Response{Str: s, Lines: lines}
+	if vals := resp.Values("+CIPSTA"); len(vals) != 2 || vals[1] != want[1].Val {
+		t.Errorf("Values: %q", vals)
+	}
+}
+
+func TestDetectFirmware(t *testing.T) {
+	for _, test := range []struct {
+		gmr     string
+		version string
+		legacy  bool
+	}{
+		{"AT version:1.7.4.0(May 11 2020 19:13:04)\nSDK version:3.0.4(9532ceb)\n", "1.7.4.0", true},
+		{"AT version:2.2.0.0(c6fa6bf - ESP32 - Jul  2 2021 06:44:05)\n", "2.2.0.0", false},
+	} {
+		fw := detectFirmware(&Response{Lines: splitLines(test.gmr)})
+		if fw.version != test.version || fw.legacy != test.legacy {
+			t.Errorf("%q: %+v", test.gmr, *fw)
+		}
+	}
+}
+
+func TestParseBootBanner(t *testing.T) {
+	for _, test := range []struct {
+		line   string
+		cause  int
+		reason string
+		mode   int
+	}{
+		{"rst:0x1 (POWERON_RESET),boot:0x13 (SPI_FAST_FLASH_BOOT)", 1, "POWERON_RESET", 0x13},
+		{"rst:0xc (SW_CPU_RESET),boot:0x8 (SPI_FAST_FLASH_BOOT)", 12, "SW_CPU_RESET", 8},
+		{" ets Jan  8 2013,rst cause:2, boot mode:(3,6)", 2, "EXT_RESET", 3},
+	} {
+		bi := parseBootBanner(test.line)
+		if bi == nil || bi.Cause != test.cause || bi.Reason != test.reason || bi.BootMode != test.mode {
+			t.Errorf("%q: %+v", test.line, bi)
+		}
+	}
+	if bi := parseBootBanner("ready"); bi != nil {
+		t.Errorf("ready: %+v", bi)
+	}
+}
+
+func TestLinkFreed(t *testing.T) {
+	d := new(Device)
+	receiverInit(&d.receiver)
+	d.receiver.mux.Store(true)
+	for i := 0; i < maxLinks; i++ {
+		d.receiver.setUsed(i, true)
+	}
+	if n := d.FreeLinks(); n != 0 {
+		t.Fatalf("FreeLinks: %d", n)
+	}
+	freed := d.LinkFreed()
+	d.receiver.setUsed(2, false)
+	select {
+	case <-freed:
+	default:
+		t.Fatal("LinkFreed: channel not closed")
+	}
+	if id := d.FreeLink(); id != 2 {
+		t.Fatalf("FreeLink: %d", id)
+	}
+}
+
+func TestAcquireDial(t *testing.T) {
+	r, _ := io.Pipe()
+	d := NewDevice("test", r, io.Discard)
+	ctx := context.Background()
+	if err := d.AcquireDial(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := d.AcquireDial(cctx); err != context.Canceled {
+		t.Errorf("AcquireDial: %v; want %v", err, context.Canceled)
+	}
+	d.ReleaseDial()
+	if err := d.AcquireDial(ctx); err != nil {
+		t.Errorf("AcquireDial after ReleaseDial: %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	r, w := io.Pipe()
+	d := NewDevice("test", r, io.Discard)
+	ch := make(chan Async, 1)
+	d.Notify(ch)
+	io.WriteString(w, "WIFI DISCONNECT\r\n")
+	if msg := <-ch; msg.Str != "WIFI DISCONNECT" {
+		t.Errorf("Notify: %+v", msg)
+	}
+	if msg := <-d.Async(); msg.Str != "WIFI DISCONNECT" {
+		t.Errorf("Async: %+v", msg)
+	}
+	d.StopNotify(ch)
+	io.WriteString(w, "WIFI CONNECTED\r\n")
+	<-d.Async()
+	select {
+	case msg := <-ch:
+		t.Errorf("StopNotify: %+v", msg)
+	default:
+	}
+}
+
+type cmdDoneTest struct {
+	lat    time.Duration
+	err    error
+	bucket int
+}
+
+var cmdDoneTests = []cmdDoneTest{
+	{100 * time.Microsecond, nil, 0},
+	{1500 * time.Microsecond, nil, 1},
+	{3 * time.Millisecond, nil, 2},
+	{100 * time.Millisecond, &ErrorESP{"ERR CODE:0x01090000"}, 7},
+	{time.Hour, ErrTimeout, LatencyBuckets - 1},
+	{50 * time.Millisecond, &ErrorESP{"ERR CODE:0x01090000"}, 6},
+	{time.Millisecond / 2, &ErrorESP{"busy p..."}, 0},
+}
+
+func TestStatsCmdDone(t *testing.T) {
+	var s stats
+	for _, test := range cmdDoneTests {
+		c := &cmd{name: "+TEST", start: time.Now().Add(-test.lat), err: test.err}
+		before := s.cmds["+TEST"]
+		var prev uint32
+		if before != nil {
+			prev = before.Latency[test.bucket]
+		}
+		s.cmdDone(c)
+		if n := s.cmds["+TEST"].Latency[test.bucket]; n != prev+1 {
+			t.Errorf("%v: bucket %d: %d != %d", test.lat, test.bucket, n, prev+1)
+		}
+	}
+	cs := s.cmds["+TEST"]
+	if cs.Count != uint(len(cmdDoneTests)) || cs.Errors != 4 || cs.Max < time.Hour {
+		t.Errorf("CmdStats: %+v", *cs)
+	}
+	want := map[string]uint{"ERR CODE:0x01090000": 2, "busy p...": 1}
+	if !reflect.DeepEqual(s.errors, want) {
+		t.Errorf("errors: %v != %v", s.errors, want)
+	}
+}
+
+func TestStatsSnapshot(t *testing.T) {
+	d := new(Device)
+	s := &d.stats
+	s.cmdDone(&cmd{name: "+GMR", start: time.Now(), err: &ErrorESP{"ERROR"}})
+	s.recv(1, 10, true)
+	st := d.Stats()
+	s.cmdDone(&cmd{name: "+GMR", start: time.Now(), err: &ErrorESP{"ERROR"}})
+	s.recv(1, 10, true)
+	if st.Cmds["+GMR"].Count != 1 || st.Errors["ERROR"] != 1 ||
+		st.Links[1].Recv != 10 || st.IPD != 1 {
+		t.Errorf("snapshot changed: %+v", st)
+	}
+	st.Cmds["+GMR"] = CmdStats{}
+	st.Errors["ERROR"] = 0
+	if s.cmds["+GMR"].Count != 2 || s.errors["ERROR"] != 2 {
+		t.Errorf("device stats changed by the snapshot")
+	}
+}
+
+// scriptDevice returns the device connected to the emulated ESP-AT module
+// that answers the commands using the script.
+func scriptDevice(t *testing.T, script esptest.Script) (*Device, *esptest.Module) {
+	m, r, w := esptest.New(t, "test", script)
+	return NewDevice("test", r, w), m
+}
+
+var initScript = esptest.Script{
+	"ATE0":         "\r\nOK\r\n",
+	"AT+GMR":       "AT version:2.2.0.0\r\n\r\nOK\r\n",
+	"AT+SYSLOG=1":  "\r\nOK\r\n",
+	"AT+GSLP=0":    "\r\nOK\r\n",
+	"AT+GSLP=1000": "\r\nOK\r\n",
+	"AT+SLEEP=2":   "\r\nOK\r\n",
+}
+
+func expectCmds(t *testing.T, m *esptest.Module, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case c := <-m.Cmds():
+			if c != w {
+				t.Errorf("command: %q != %q", c, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("command %q not received", w)
+		}
+	}
+}
+
+func TestDeepSleep(t *testing.T) {
+	d, m := scriptDevice(t, initScript)
+	if err := d.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "ATE0", "AT+GMR", "AT+SYSLOG=1")
+
+	// Commands are queued until the device wakes up and are preceded by the
+	// reinitialization.
+	if err := d.Sleep(DeepSleep, &Wake{Time: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "AT+GSLP=1000")
+	done := make(chan error, 1)
+	go func() {
+		_, err := d.Cmd("+GMR")
+		done <- err
+	}()
+	select {
+	case c := <-m.Cmds():
+		t.Fatalf("command %q sent to the sleeping device", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.Write("ready\r\n")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "ATE0", "AT+GMR", "AT+SYSLOG=1", "AT+GMR")
+	select {
+	case msg := <-d.Async():
+		t.Errorf("Async: %+v", msg)
+	default:
+	}
+
+	// Rejection.
+	if err := d.Sleep(DeepSleep, &Wake{Reject: true}); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "AT+GSLP=0")
+	if _, err := d.Cmd("+GMR"); !errors.Is(err, ErrSleeping) {
+		t.Errorf("Reject: %v", err)
+	}
+
+	// The lost ready message after the wakeup time.
+	d.Lock()
+	d.sleep.reject = false
+	d.sleep.until = time.Now().Add(-time.Hour)
+	d.Unlock()
+	start := time.Now()
+	if _, err := d.Cmd("+GMR"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("lost ready: %v", err)
+	}
+	if dt := time.Since(start); dt < 2*time.Second || dt > 3*time.Second {
+		t.Errorf("lost ready: timeout %v", dt)
+	}
+}
+
+func TestLightSleep(t *testing.T) {
+	d, m := scriptDevice(t, initScript)
+	if err := d.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Sleep(LightSleep, &Wake{Source: WakeNone, Reject: true}); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "ATE0", "AT+GMR", "AT+SYSLOG=1", "AT+SLEEP=2")
+	if _, err := d.Cmd("+GMR"); !errors.Is(err, ErrSleeping) {
+		t.Errorf("Reject: %v", err)
+	}
+	hooks := 0
+	d.SetWakeHook(func() error { hooks++; return nil })
+	for i := 0; i < 2; i++ {
+		if _, err := d.Cmd("+GMR"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hooks != 2 {
+		t.Errorf("wake hook called %d times", hooks)
+	}
+}
+
+func TestCmdTimeout(t *testing.T) {
+	d, m := scriptDevice(t, esptest.Script{
+		"AT+SLOW": "",
+		"AT+GMR":  "AT version:2.2.0.0\r\n\r\nOK\r\n",
+	})
+	gmr := func() <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			resp, err := d.Cmd("+GMR")
+			if err == nil && resp.Str != "AT version:2.2.0.0\n" {
+				t.Errorf("+GMR: %q", resp.Str)
+			}
+			done <- err
+		}()
+		return done
+	}
+	if _, err := d.CmdTimeout(50*time.Millisecond, "+SLOW"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("+SLOW: %v", err)
+	}
+	expectCmds(t, m, "AT+SLOW")
+
+	// The next command isn't sent before the late response.
+	if _, err := d.CmdTimeout(50*time.Millisecond, "+GMR"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("+GMR: %v", err)
+	}
+	done := gmr()
+	select {
+	case c := <-m.Cmds():
+		t.Fatalf("command %q sent before the late response", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.Write("\r\nERROR\r\n") // the late response to +SLOW
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "AT+GMR")
+
+	// The late response will not come after the device restart.
+	if _, err := d.CmdTimeout(50*time.Millisecond, "+SLOW"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("+SLOW: %v", err)
+	}
+	expectCmds(t, m, "AT+SLOW")
+	m.Write("ready\r\n")
+	if msg := <-d.Async(); msg.Str != "ready" {
+		t.Errorf("Async: %+v", msg)
+	}
+	if err := <-gmr(); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "AT+GMR")
+}
+
+func TestMonitor(t *testing.T) {
+	script := esptest.Script{"AT+RST": "\r\nOK\r\nets Jul 29 2019 12:21:46\r\nready\r\n"}
+	for k, v := range initScript {
+		script[k] = v
+	}
+	d, m := scriptDevice(t, script)
+	if err := d.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	setups := make(chan struct{}, 1)
+	hardReset := func() error {
+		m.SetMute(false)
+		m.Write("ready\r\n")
+		return nil
+	}
+	mon := StartMonitor(d, MonitorConfig{
+		Interval:  20 * time.Millisecond,
+		Timeout:   20 * time.Millisecond,
+		MaxFails:  2,
+		HardReset: hardReset,
+	})
+	defer mon.Stop()
+	mon.Register(func(d *Device) error {
+		setups <- struct{}{}
+		return nil
+	})
+	m.SetMute(true)
+	prev := Healthy
+	for _, want := range []HealthState{Unresponsive, SoftReset, HardReset, Healthy} {
+		ev := HealthEvent{State: prev}
+		for ev.State == prev { // every probe timeout is reported
+			select {
+			case ev = <-mon.Events():
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no %v event", want)
+			}
+		}
+		if ev.State != want {
+			t.Fatalf("event: %v != %v (%v)", ev.State, want, ev.Err)
+		}
+		prev = ev.State
+	}
+	select {
+	case <-setups:
+	default:
+		t.Error("setup not called")
+	}
+	if _, err := d.Cmd("+GMR"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMonitorSleep(t *testing.T) {
+	d, m := scriptDevice(t, initScript)
+	if err := d.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Sleep(LightSleep, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "ATE0", "AT+GMR", "AT+SYSLOG=1", "AT+SLEEP=2")
+	mon := StartMonitor(d, MonitorConfig{Interval: 10 * time.Millisecond})
+	defer mon.Stop()
+	select {
+	case c := <-m.Cmds():
+		t.Errorf("command %q sent to the sleeping device", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReinit(t *testing.T) {
+	script := esptest.Script{"AT+SYSMSG=2": "\r\nOK\r\n"}
+	for k, v := range initScript {
+		script[k] = v
+	}
+	d, m := scriptDevice(t, script)
+	if err := d.Init(false, WithSysMsg(SysMsgLinkConn)); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "ATE0", "AT+GMR", "AT+SYSLOG=1", "AT+SYSMSG=2")
+	if err := d.Reinit(false); err != nil {
+		t.Fatal(err)
+	}
+	expectCmds(t, m, "ATE0", "AT+GMR", "AT+SYSLOG=1", "AT+SYSMSG=2")
+	if m := SysMsg(d.receiver.sysmsg.Load()); m != SysMsgLinkConn {
+		t.Errorf("sysmsg: %d", m)
+	}
+}
+
+func TestResetLinks(t *testing.T) {
+	d, m := scriptDevice(t, esptest.Script{
+		"AT+CIPMUX=1":                   "\r\nOK\r\n",
+		`AT+CIPSTART=0,"TCP","host",80`: "0,CONNECT\r\n\r\nOK\r\n",
+		`AT+CIPSTART=1,"TCP","host",80`: "1,CONNECT\r\n\r\nOK\r\n",
+	})
+	if _, err := d.Cmd("+CIPMUX=1"); err != nil {
+		t.Fatal(err)
+	}
+	var conns []*Conn
+	for id := 0; id < 2; id++ {
+		c, err := d.CmdConn("+CIPSTART=", id, "TCP", "host", 80)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	if n := d.FreeLinks(); n != maxLinks-2 {
+		t.Fatalf("free links: %d", n)
+	}
+	freed := d.LinkFreed()
+	m.Write("ready\r\n")
+	select {
+	case <-freed:
+	case <-time.After(time.Second):
+		t.Fatal("LinkFreed not closed")
+	}
+	for _, c := range conns {
+		select {
+		case _, ok := <-c.Ch:
+			if ok {
+				t.Errorf("conn %d: data received", c.ID)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("conn %d: not closed", c.ID)
+		}
+	}
+	if d.MultiConn() {
+		t.Error("multiple connection mode not cleared")
+	}
+	if n := d.FreeLinks(); n != 1 {
+		t.Errorf("free links: %d", n)
+	}
+}
@@ -0,0 +1,177 @@
+package espat
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BootInfo contains the information parsed from the ROM boot banner, e.g.:
+//
+//	rst:0x1 (POWERON_RESET),boot:0x13 (SPI_FAST_FLASH_BOOT)  (ESP32)
+//	 ets Jan  8 2013,rst cause:2, boot mode:(3,6)             (ESP8266)
+type BootInfo struct {
+	Time     time.Time // time the banner was received
+	Cause    int       // reset cause code
+	Reason   string    // reset reason name, e.g. "POWERON_RESET"
+	BootMode int       // boot mode (strapping pins)
+	Banner   string    // the original line
+}
+
+// LastBoot returns the information about the last boot of the device or nil
+// if no boot banner has been received so far. The banner line is also sent
+// to the Async channel so the application can detect unexpected restarts.
+func (d *Device) LastBoot() *BootInfo {
+	return d.receiver.boot.Load()
+}
+
+var esp8266ResetReasons = [...]string{
+	1: "POWERON_RESET",
+	2: "EXT_RESET",
+	3: "SW_RESET",
+	4: "WDT_RESET",
+	5: "DEEPSLEEP_RESET",
+	6: "EXT_SYS_RESET",
+}
+
+// parseBootBanner parses the ROM boot banner line. It returns nil if the line
+// isn't a boot banner.
+func parseBootBanner(line string) *BootInfo {
+	if strings.HasPrefix(line, "rst:0x") {
+		// ESP32 family
+		bi := &BootInfo{Banner: line}
+		s := line[6:]
+		i := 0
+		for i < len(s) && isHex(s[i]) {
+			i++
+		}
+		c, _ := strconv.ParseInt(s[:i], 16, 32)
+		bi.Cause = int(c)
+		s = s[i:]
+		if i = strings.IndexByte(s, '('); i >= 0 {
+			if k := strings.IndexByte(s[i:], ')'); k >= 0 {
+				bi.Reason = s[i+1 : i+k]
+			}
+		}
+		if i = strings.Index(s, "boot:0x"); i >= 0 {
+			s = s[i+7:]
+			i = 0
+			for i < len(s) && isHex(s[i]) {
+				i++
+			}
+			m, _ := strconv.ParseInt(s[:i], 16, 32)
+			bi.BootMode = int(m)
+		}
+		bi.Time = time.Now()
+		return bi
+	}
+	if i := strings.Index(line, "rst cause:"); i >= 0 {
+		// ESP8266
+		bi := &BootInfo{Banner: line}
+		s := line[i+10:]
+		i = 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		bi.Cause, _ = strconv.Atoi(s[:i])
+		if uint(bi.Cause) < uint(len(esp8266ResetReasons)) {
+			bi.Reason = esp8266ResetReasons[bi.Cause]
+		}
+		if i = strings.Index(s, "boot mode:("); i >= 0 {
+			s = s[i+11:]
+			i = 0
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			bi.BootMode, _ = strconv.Atoi(s[:i])
+		}
+		bi.Time = time.Now()
+		return bi
+	}
+	return nil
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// HardReset resets the device using the EN pin connected to the RTS line of
+// the transport (the auto-reset circuit used by esptool) and waits for the
+// ready message (2 second max.). Use Reinit(false) to initialize the device
+// after the hard reset.
+func (d *Device) HardReset() error {
+	const name = "hard reset"
+	t := d.t
+	if t == nil {
+		return &Error{d.name, name, ErrNoTransport}
+	}
+	d.cmdx.Lock()
+	defer d.cmdx.Unlock()
+	d.sleep.mode.Store(int32(NoSleep)) // reset wakes up the device
+	// Discard pending messages.
+emptying:
+	for {
+		select {
+		case <-d.Async():
+		default:
+			break emptying
+		}
+	}
+	if err := resetPulse(t, false); err != nil {
+		return &Error{d.name, name, err}
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-d.Async():
+			// There may be many noise-like errors afer reset, ignore all.
+			if msg.Str == "ready" {
+				return nil
+			}
+		case <-timeout:
+			return &Error{d.name, "ready", ErrTimeout}
+		}
+	}
+}
+
+// EnterBootloader resets the device into the ROM serial bootloader using the
+// EN (RTS) and IO0 (DTR) pins. The device doesn't respond to AT commands in
+// this mode. Use HardReset to return to the AT firmware.
+func (d *Device) EnterBootloader() error {
+	t := d.t
+	if t == nil {
+		return &Error{d.name, "bootloader", ErrNoTransport}
+	}
+	d.cmdx.Lock()
+	defer d.cmdx.Unlock()
+	if err := resetPulse(t, true); err != nil {
+		return &Error{d.name, "bootloader", err}
+	}
+	return nil
+}
+
+// resetPulse implements the esptool classic reset sequence. The RTS and DTR
+// lines are inverted by the auto-reset circuit: RTS on means EN low, DTR on
+// means IO0 low.
+func resetPulse(t Transport, bootloader bool) error {
+	if err := t.SetDTR(false); err != nil { // IO0 high
+		return err
+	}
+	if err := t.SetRTS(true); err != nil { // EN low
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+	if bootloader {
+		if err := t.SetDTR(true); err != nil { // IO0 low
+			return err
+		}
+	}
+	if err := t.SetRTS(false); err != nil { // EN high
+		return err
+	}
+	if bootloader {
+		time.Sleep(50 * time.Millisecond)
+		return t.SetDTR(false) // IO0 high
+	}
+	return nil
+}
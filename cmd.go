@@ -3,18 +3,109 @@ package espat
 import (
 	"errors"
 	"io"
+	"strconv"
 	"sync"
+	"time"
 )
 
+// Response represents the response to the AT command.
+//
+// Str contains all the intermediate lines (the lines received before the
+// final OK) joined with '\n'. Lines contains the same lines in the order of
+// arrival with the "+CMD:" prefixes split off. Lines is also set if the
+// command failed with ErrorESP.
 type Response struct {
-	Str  string
-	Int  int
-	Conn *Conn
+	Str   string
+	Int   int
+	Conn  *Conn
+	Lines []Line
+}
+
+// Line represents an intermediate line of the response.
+type Line struct {
+	Cmd string // "+CMD" prefix without the colon or "" for the free text line
+	Val string // the rest of the line
+}
+
+// Values returns the values of all lines with the given Cmd prefix. Use the
+// empty prefix to obtain the free text lines.
+func (r *Response) Values(cmd string) []string {
+	var vals []string
+	for _, l := range r.Lines {
+		if l.Cmd == cmd {
+			vals = append(vals, l.Val)
+		}
+	}
+	return vals
+}
+
+// Value returns the value of the first line with the given Cmd prefix. It
+// reports whether such line was found.
+func (r *Response) Value(cmd string) (string, bool) {
+	for _, l := range r.Lines {
+		if l.Cmd == cmd {
+			return l.Val, true
+		}
+	}
+	return "", false
+}
+
+// Args returns the result of SplitArgs applied to the value of the first line
+// with the given Cmd prefix.
+func (r *Response) Args(cmd string) []string {
+	v, _ := r.Value(cmd)
+	return SplitArgs(v)
+}
+
+// splitLines splits the '\n' terminated lines of s.
+func splitLines(s string) []Line {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	lines := make([]Line, 0, n)
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] != '\n' {
+			i++
+		}
+		line := s[:i]
+		if i < len(s) {
+			i++
+		}
+		s = s[i:]
+		var l Line
+		if len(line) > 1 && line[0] == '+' {
+			k := 1
+			for k < len(line) && line[k] != ':' && line[k] != ' ' {
+				k++
+			}
+			if k < len(line) && line[k] == ':' {
+				l.Cmd, line = line[:k], line[k+1:]
+			}
+		}
+		l.Val = line
+		lines = append(lines, l)
+	}
+	return lines
 }
 
 type cmd struct {
-	name string
-	args []any
+	name    string
+	args    []any
+	start   time.Time
+	timeout time.Duration
+
+	// The timed out command remains registered in the receiver until its
+	// late response is received (abandoned is set) or the device restarts
+	// (rebooted is closed).
+	abandoned bool
+	rebooted  <-chan struct{}
 
 	ready sync.Mutex
 	resp  Response
@@ -23,16 +114,80 @@ type cmd struct {
 
 func processCmd(d *Device) {
 	var buf [128]byte
+	tim := time.NewTimer(0)
+	<-tim.C
+	var late *cmd // timed out command that waits for its response
 	for cmd := range d.cmdq {
+		cmd.start = time.Now()
+		if late != nil {
+			// The next command cannot be sent before the late response is
+			// received because the receiver would pass the late response
+			// to it.
+			if !d.handOver(late, tim, cmd.timeout) {
+				cmd.err = ErrTimeout
+				d.stats.cmdDone(cmd)
+				cmd.ready.Unlock()
+				continue
+			}
+			late = nil
+		}
+		cmd.rebooted = *d.receiver.rebooted.Load()
 		if cmd.name != "" {
 			if err := writeCmd(d.w, &buf, cmd.name, cmd.args); err != nil {
 				cmd.err = err
+				d.stats.cmdDone(cmd)
 				cmd.ready.Unlock()
 				continue
 			}
+			if cmd.name == "+CIPSEND=" {
+				d.stats.sent(cmd.args)
+			}
+		}
+		if !d.handOver(cmd, tim, cmd.timeout) {
+			cmd.abandoned = true
+			cmd.err = ErrTimeout
+			d.stats.cmdDone(cmd)
+			cmd.ready.Unlock()
+			late = cmd
+		}
+	}
+}
+
+// handOver passes c to the receiver that waits for the response. It reports
+// false if the timeout (if > 0) expired before. The abandoned command is
+// also considered handed over if the device restarted after it was sent. The
+// response that is ready when the timeout expires wins.
+func (d *Device) handOver(c *cmd, tim *time.Timer, timeout time.Duration) bool {
+	var rebooted <-chan struct{}
+	if c.abandoned {
+		rebooted = c.rebooted
+	}
+	if timeout <= 0 {
+		select {
+		case d.receiver.cmd <- c:
+		case <-rebooted:
+		}
+		return true
+	}
+	tim.Reset(timeout)
+	select {
+	case d.receiver.cmd <- c:
+	case <-rebooted:
+	case <-tim.C:
+		// Select chooses randomly if the receiver is also ready.
+		select {
+		case d.receiver.cmd <- c:
+			return true
+		case <-rebooted:
+			return true
+		default:
+			return false
 		}
-		d.receiver.cmd <- cmd
 	}
+	if !tim.Stop() {
+		<-tim.C
+	}
+	return true
 }
 
 func writeCmd(w io.Writer, buf *[128]byte, name string, args []any) error {
@@ -91,6 +246,16 @@ func writeCmd(w io.Writer, buf *[128]byte, name string, args []any) error {
 					l--
 				}
 			}
+		case int64:
+			var nb [20]byte
+			for _, c := range strconv.AppendInt(nb[:0], a, 10) {
+				insert(c)
+			}
+		case uint64:
+			var nb [20]byte
+			for _, c := range strconv.AppendUint(nb[:0], a, 10) {
+				insert(c)
+			}
 		default:
 			if arg != nil {
 				return ErrArgType
@@ -0,0 +1,157 @@
+// Package cmds provides typed ESP-AT commands generated from the command
+// catalog (cmds.txt). Every command is represented by a struct that contains
+// the set arguments. The Set, Query and Exec methods use Device.Cmd to execute
+// the AT+<NAME>=..., AT+<NAME>? and AT+<NAME> forms of the command. The query
+// response is returned as the <NAME>Resp struct or nil if the device didn't
+// report the value (e.g. AT+CWJAP? if the station isn't connected). The Args
+// method returns the set arguments so the command can be also executed in
+// other way (e.g. using Device.CmdTimeout).
+//
+// The commands fail with ErrUnsupported if the firmware version detected by
+// Device.Init is older than the one returned by the MinVersion method.
+//
+// Example:
+//
+//	_, err := cmds.CWJAP{SSID: "ssid", Password: "passwd"}.Set(d)
+//	...
+//	ap, err := cmds.CWJAP{}.Query(d)
+//	...
+//	fmt.Println(ap.BSSID, ap.RSSI)
+package cmds
+
+//go:generate go run gen.go
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/embeddedgo/espat"
+)
+
+// Version represents the ESP-AT firmware version.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// ParseVersion parses the version in the major.minor.patch format. The
+// following components are ignored (e.g. "2.2.0.0" is parsed as 2.2.0).
+func ParseVersion(s string) (v Version, ok bool) {
+	f := strings.SplitN(s, ".", 4)
+	if len(f) < 3 {
+		return v, false
+	}
+	var err [3]error
+	v.Major, err[0] = strconv.Atoi(f[0])
+	v.Minor, err[1] = strconv.Atoi(f[1])
+	v.Patch, err[2] = strconv.Atoi(f[2])
+	return v, err[0] == nil && err[1] == nil && err[2] == nil
+}
+
+// Less reports whether v < w.
+func (v Version) Less(w Version) bool {
+	if v.Major != w.Major {
+		return v.Major < w.Major
+	}
+	if v.Minor != w.Minor {
+		return v.Minor < w.Minor
+	}
+	return v.Patch < w.Patch
+}
+
+func (v Version) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." +
+		strconv.Itoa(v.Patch)
+}
+
+// ErrUnsupported is returned in the espat.Error Err field if the command isn't
+// supported by the firmware.
+var ErrUnsupported = errors.New("unsupported by firmware")
+
+// supported checks the firmware version. The unknown version (Init not called
+// or not detected) is assumed to support all commands.
+func supported(d *espat.Device, min Version, name string) error {
+	v, ok := ParseVersion(d.Version())
+	if ok && v.Less(min) {
+		return &espat.Error{Dev: d.Name(), Cmd: name, Err: ErrUnsupported}
+	}
+	return nil
+}
+
+func cmd(d *espat.Device, min Version, name string, args ...any) (*espat.Response, error) {
+	if err := supported(d, min, name); err != nil {
+		return new(espat.Response), err
+	}
+	return d.Cmd(name, args...)
+}
+
+// args removes the trailing nil arguments.
+func args(a ...any) []any {
+	n := len(a)
+	for n > 0 && a[n-1] == nil {
+		n--
+	}
+	return a[:n]
+}
+
+func boolArg(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func optBool(b bool) any {
+	if !b {
+		return nil
+	}
+	return 1
+}
+
+// parser parses the query response arguments.
+type parser struct {
+	args []string
+	i    int
+	err  bool
+}
+
+func (p *parser) next() string {
+	if p.i >= len(p.args) {
+		return ""
+	}
+	a := p.args[p.i]
+	p.i++
+	return a
+}
+
+func (p *parser) string() string {
+	return p.next()
+}
+
+func (p *parser) int() int {
+	a := p.next()
+	if a == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(a)
+	if err != nil {
+		p.err = true
+	}
+	return i
+}
+
+func (p *parser) bool() bool {
+	return p.int() != 0
+}
+
+// query returns nil parser if the response doesn't contain the name line.
+func query(d *espat.Device, min Version, name string) (*parser, error) {
+	resp, err := cmd(d, min, name+"?")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := resp.Value(name); !ok {
+		return nil, nil
+	}
+	return &parser{args: resp.Args(name)}, nil
+}
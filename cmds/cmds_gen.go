@@ -0,0 +1,912 @@
+// Code generated by gen.go from cmds.txt; DO NOT EDIT.
+
+package cmds
+
+import "github.com/embeddedgo/espat"
+
+// RST represents the AT+RST command.
+type RST struct{}
+
+// MinVersion returns the minimum firmware version that supports RST.
+func (RST) MinVersion() Version { return Version{1, 0, 0} }
+
+// Exec executes AT+RST.
+func (RST) Exec(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+RST")
+}
+
+// GMR represents the AT+GMR command.
+type GMR struct{}
+
+// MinVersion returns the minimum firmware version that supports GMR.
+func (GMR) MinVersion() Version { return Version{1, 0, 0} }
+
+// Exec executes AT+GMR.
+func (GMR) Exec(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+GMR")
+}
+
+// RESTORE represents the AT+RESTORE command.
+type RESTORE struct{}
+
+// MinVersion returns the minimum firmware version that supports RESTORE.
+func (RESTORE) MinVersion() Version { return Version{1, 0, 0} }
+
+// Exec executes AT+RESTORE.
+func (RESTORE) Exec(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+RESTORE")
+}
+
+// SYSRAM represents the AT+SYSRAM command.
+type SYSRAM struct{}
+
+// MinVersion returns the minimum firmware version that supports SYSRAM.
+func (SYSRAM) MinVersion() Version { return Version{2, 0, 0} }
+
+// SYSRAMResp represents the response to the AT+SYSRAM? query.
+type SYSRAMResp struct {
+	Free    int
+	MinFree int
+}
+
+// Query executes AT+SYSRAM?
+func (SYSRAM) Query(d *espat.Device) (*SYSRAMResp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+SYSRAM")
+	if p == nil {
+		return nil, err
+	}
+	r := new(SYSRAMResp)
+	r.Free = p.int()
+	r.MinFree = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+SYSRAM?")
+	}
+	return r, nil
+}
+
+// SYSSTORE represents the AT+SYSSTORE command.
+type SYSSTORE struct {
+	Store bool
+}
+
+// MinVersion returns the minimum firmware version that supports SYSSTORE.
+func (SYSSTORE) MinVersion() Version { return Version{2, 0, 0} }
+
+// Args returns the arguments of AT+SYSSTORE=...
+func (c SYSSTORE) Args() []any {
+	return args(boolArg(c.Store))
+}
+
+// Set executes AT+SYSSTORE=...
+func (c SYSSTORE) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 0, 0}, "+SYSSTORE=", c.Args()...)
+}
+
+// SYSSTOREResp represents the response to the AT+SYSSTORE? query.
+type SYSSTOREResp struct {
+	Store bool
+}
+
+// Query executes AT+SYSSTORE?
+func (SYSSTORE) Query(d *espat.Device) (*SYSSTOREResp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+SYSSTORE")
+	if p == nil {
+		return nil, err
+	}
+	r := new(SYSSTOREResp)
+	r.Store = p.bool()
+	if p.err {
+		return nil, espat.ParseError(d, "+SYSSTORE?")
+	}
+	return r, nil
+}
+
+// SYSMSG represents the AT+SYSMSG command.
+type SYSMSG struct {
+	State int
+}
+
+// MinVersion returns the minimum firmware version that supports SYSMSG.
+func (SYSMSG) MinVersion() Version { return Version{2, 0, 0} }
+
+// Args returns the arguments of AT+SYSMSG=...
+func (c SYSMSG) Args() []any {
+	return args(c.State)
+}
+
+// Set executes AT+SYSMSG=...
+func (c SYSMSG) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 0, 0}, "+SYSMSG=", c.Args()...)
+}
+
+// SYSMSGResp represents the response to the AT+SYSMSG? query.
+type SYSMSGResp struct {
+	State int
+}
+
+// Query executes AT+SYSMSG?
+func (SYSMSG) Query(d *espat.Device) (*SYSMSGResp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+SYSMSG")
+	if p == nil {
+		return nil, err
+	}
+	r := new(SYSMSGResp)
+	r.State = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+SYSMSG?")
+	}
+	return r, nil
+}
+
+// SYSTIMESTAMP represents the AT+SYSTIMESTAMP command.
+type SYSTIMESTAMP struct {
+	Timestamp int
+}
+
+// MinVersion returns the minimum firmware version that supports SYSTIMESTAMP.
+func (SYSTIMESTAMP) MinVersion() Version { return Version{2, 0, 0} }
+
+// Args returns the arguments of AT+SYSTIMESTAMP=...
+func (c SYSTIMESTAMP) Args() []any {
+	return args(c.Timestamp)
+}
+
+// Set executes AT+SYSTIMESTAMP=...
+func (c SYSTIMESTAMP) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 0, 0}, "+SYSTIMESTAMP=", c.Args()...)
+}
+
+// SYSTIMESTAMPResp represents the response to the AT+SYSTIMESTAMP? query.
+type SYSTIMESTAMPResp struct {
+	Timestamp int
+}
+
+// Query executes AT+SYSTIMESTAMP?
+func (SYSTIMESTAMP) Query(d *espat.Device) (*SYSTIMESTAMPResp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+SYSTIMESTAMP")
+	if p == nil {
+		return nil, err
+	}
+	r := new(SYSTIMESTAMPResp)
+	r.Timestamp = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+SYSTIMESTAMP?")
+	}
+	return r, nil
+}
+
+// SLEEP represents the AT+SLEEP command.
+type SLEEP struct {
+	Mode int
+}
+
+// MinVersion returns the minimum firmware version that supports SLEEP.
+func (SLEEP) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+SLEEP=...
+func (c SLEEP) Args() []any {
+	return args(c.Mode)
+}
+
+// Set executes AT+SLEEP=...
+func (c SLEEP) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+SLEEP=", c.Args()...)
+}
+
+// SLEEPResp represents the response to the AT+SLEEP? query.
+type SLEEPResp struct {
+	Mode int
+}
+
+// Query executes AT+SLEEP?
+func (SLEEP) Query(d *espat.Device) (*SLEEPResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+SLEEP")
+	if p == nil {
+		return nil, err
+	}
+	r := new(SLEEPResp)
+	r.Mode = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+SLEEP?")
+	}
+	return r, nil
+}
+
+// GSLP represents the AT+GSLP command.
+type GSLP struct {
+	Time int
+}
+
+// MinVersion returns the minimum firmware version that supports GSLP.
+func (GSLP) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+GSLP=...
+func (c GSLP) Args() []any {
+	return args(c.Time)
+}
+
+// Set executes AT+GSLP=...
+func (c GSLP) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+GSLP=", c.Args()...)
+}
+
+// UARTCUR represents the AT+UART_CUR command.
+type UARTCUR struct {
+	Baudrate    int
+	DataBits    int
+	StopBits    int
+	Parity      int
+	FlowControl int
+}
+
+// MinVersion returns the minimum firmware version that supports UARTCUR.
+func (UARTCUR) MinVersion() Version { return Version{1, 2, 0} }
+
+// Args returns the arguments of AT+UART_CUR=...
+func (c UARTCUR) Args() []any {
+	return args(c.Baudrate, c.DataBits, c.StopBits, c.Parity, c.FlowControl)
+}
+
+// Set executes AT+UART_CUR=...
+func (c UARTCUR) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 2, 0}, "+UART_CUR=", c.Args()...)
+}
+
+// UARTCURResp represents the response to the AT+UART_CUR? query.
+type UARTCURResp struct {
+	Baudrate    int
+	DataBits    int
+	StopBits    int
+	Parity      int
+	FlowControl int
+}
+
+// Query executes AT+UART_CUR?
+func (UARTCUR) Query(d *espat.Device) (*UARTCURResp, error) {
+	p, err := query(d, Version{1, 2, 0}, "+UART_CUR")
+	if p == nil {
+		return nil, err
+	}
+	r := new(UARTCURResp)
+	r.Baudrate = p.int()
+	r.DataBits = p.int()
+	r.StopBits = p.int()
+	r.Parity = p.int()
+	r.FlowControl = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+UART_CUR?")
+	}
+	return r, nil
+}
+
+// CWMODE represents the AT+CWMODE command.
+type CWMODE struct {
+	Mode        int
+	AutoConnect bool
+}
+
+// MinVersion returns the minimum firmware version that supports CWMODE.
+func (CWMODE) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CWMODE=...
+func (c CWMODE) Args() []any {
+	return args(c.Mode, optBool(c.AutoConnect))
+}
+
+// Set executes AT+CWMODE=...
+func (c CWMODE) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWMODE=", c.Args()...)
+}
+
+// CWMODEResp represents the response to the AT+CWMODE? query.
+type CWMODEResp struct {
+	Mode int
+}
+
+// Query executes AT+CWMODE?
+func (CWMODE) Query(d *espat.Device) (*CWMODEResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+CWMODE")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWMODEResp)
+	r.Mode = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWMODE?")
+	}
+	return r, nil
+}
+
+// CWSTATE represents the AT+CWSTATE command.
+type CWSTATE struct{}
+
+// MinVersion returns the minimum firmware version that supports CWSTATE.
+func (CWSTATE) MinVersion() Version { return Version{2, 1, 0} }
+
+// CWSTATEResp represents the response to the AT+CWSTATE? query.
+type CWSTATEResp struct {
+	State int
+	SSID  string
+}
+
+// Query executes AT+CWSTATE?
+func (CWSTATE) Query(d *espat.Device) (*CWSTATEResp, error) {
+	p, err := query(d, Version{2, 1, 0}, "+CWSTATE")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWSTATEResp)
+	r.State = p.int()
+	r.SSID = p.string()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWSTATE?")
+	}
+	return r, nil
+}
+
+// CWJAP represents the AT+CWJAP command.
+type CWJAP struct {
+	SSID           string
+	Password       string
+	BSSID          string
+	PCIOnly        bool
+	ReconnInterval int
+	ListenInterval int
+	ScanMode       int
+	JAPTimeout     int
+	PMF            int
+}
+
+// MinVersion returns the minimum firmware version that supports CWJAP.
+func (CWJAP) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CWJAP=...
+func (c CWJAP) Args() []any {
+	return args(c.SSID, c.Password, espat.Opt(c.BSSID), optBool(c.PCIOnly), espat.Opt(c.ReconnInterval), espat.Opt(c.ListenInterval), espat.Opt(c.ScanMode), espat.Opt(c.JAPTimeout), espat.Opt(c.PMF))
+}
+
+// Set executes AT+CWJAP=...
+func (c CWJAP) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWJAP=", c.Args()...)
+}
+
+// CWJAPResp represents the response to the AT+CWJAP? query.
+type CWJAPResp struct {
+	SSID           string
+	BSSID          string
+	Channel        int
+	RSSI           int
+	PCIOnly        bool
+	ReconnInterval int
+	ListenInterval int
+	ScanMode       int
+	PMF            int
+}
+
+// Query executes AT+CWJAP?
+func (CWJAP) Query(d *espat.Device) (*CWJAPResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+CWJAP")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWJAPResp)
+	r.SSID = p.string()
+	r.BSSID = p.string()
+	r.Channel = p.int()
+	r.RSSI = p.int()
+	r.PCIOnly = p.bool()
+	r.ReconnInterval = p.int()
+	r.ListenInterval = p.int()
+	r.ScanMode = p.int()
+	r.PMF = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWJAP?")
+	}
+	return r, nil
+}
+
+// Exec executes AT+CWJAP.
+func (CWJAP) Exec(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWJAP")
+}
+
+// CWRECONNCFG represents the AT+CWRECONNCFG command.
+type CWRECONNCFG struct {
+	Interval    int
+	RepeatCount int
+}
+
+// MinVersion returns the minimum firmware version that supports CWRECONNCFG.
+func (CWRECONNCFG) MinVersion() Version { return Version{2, 1, 0} }
+
+// Args returns the arguments of AT+CWRECONNCFG=...
+func (c CWRECONNCFG) Args() []any {
+	return args(c.Interval, c.RepeatCount)
+}
+
+// Set executes AT+CWRECONNCFG=...
+func (c CWRECONNCFG) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 1, 0}, "+CWRECONNCFG=", c.Args()...)
+}
+
+// CWRECONNCFGResp represents the response to the AT+CWRECONNCFG? query.
+type CWRECONNCFGResp struct {
+	Interval    int
+	RepeatCount int
+}
+
+// Query executes AT+CWRECONNCFG?
+func (CWRECONNCFG) Query(d *espat.Device) (*CWRECONNCFGResp, error) {
+	p, err := query(d, Version{2, 1, 0}, "+CWRECONNCFG")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWRECONNCFGResp)
+	r.Interval = p.int()
+	r.RepeatCount = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWRECONNCFG?")
+	}
+	return r, nil
+}
+
+// CWQAP represents the AT+CWQAP command.
+type CWQAP struct{}
+
+// MinVersion returns the minimum firmware version that supports CWQAP.
+func (CWQAP) MinVersion() Version { return Version{1, 0, 0} }
+
+// Exec executes AT+CWQAP.
+func (CWQAP) Exec(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWQAP")
+}
+
+// CWAUTOCONN represents the AT+CWAUTOCONN command.
+type CWAUTOCONN struct {
+	Enable bool
+}
+
+// MinVersion returns the minimum firmware version that supports CWAUTOCONN.
+func (CWAUTOCONN) MinVersion() Version { return Version{1, 3, 0} }
+
+// Args returns the arguments of AT+CWAUTOCONN=...
+func (c CWAUTOCONN) Args() []any {
+	return args(boolArg(c.Enable))
+}
+
+// Set executes AT+CWAUTOCONN=...
+func (c CWAUTOCONN) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 3, 0}, "+CWAUTOCONN=", c.Args()...)
+}
+
+// CWAUTOCONNResp represents the response to the AT+CWAUTOCONN? query.
+type CWAUTOCONNResp struct {
+	Enable bool
+}
+
+// Query executes AT+CWAUTOCONN?
+func (CWAUTOCONN) Query(d *espat.Device) (*CWAUTOCONNResp, error) {
+	p, err := query(d, Version{1, 3, 0}, "+CWAUTOCONN")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWAUTOCONNResp)
+	r.Enable = p.bool()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWAUTOCONN?")
+	}
+	return r, nil
+}
+
+// CWLAPOPT represents the AT+CWLAPOPT command.
+type CWLAPOPT struct {
+	SortEnable   bool
+	PrintMask    int
+	RSSIFilter   int
+	AuthModeMask int
+}
+
+// MinVersion returns the minimum firmware version that supports CWLAPOPT.
+func (CWLAPOPT) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CWLAPOPT=...
+func (c CWLAPOPT) Args() []any {
+	return args(boolArg(c.SortEnable), c.PrintMask, espat.Opt(c.RSSIFilter), espat.Opt(c.AuthModeMask))
+}
+
+// Set executes AT+CWLAPOPT=...
+func (c CWLAPOPT) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWLAPOPT=", c.Args()...)
+}
+
+// CWSAP represents the AT+CWSAP command.
+type CWSAP struct {
+	SSID     string
+	Password string
+	Channel  int
+	ECN      int
+	MaxConn  int
+	Hidden   bool
+}
+
+// MinVersion returns the minimum firmware version that supports CWSAP.
+func (CWSAP) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CWSAP=...
+func (c CWSAP) Args() []any {
+	return args(c.SSID, c.Password, c.Channel, c.ECN, espat.Opt(c.MaxConn), optBool(c.Hidden))
+}
+
+// Set executes AT+CWSAP=...
+func (c CWSAP) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWSAP=", c.Args()...)
+}
+
+// CWSAPResp represents the response to the AT+CWSAP? query.
+type CWSAPResp struct {
+	SSID     string
+	Password string
+	Channel  int
+	ECN      int
+	MaxConn  int
+	Hidden   bool
+}
+
+// Query executes AT+CWSAP?
+func (CWSAP) Query(d *espat.Device) (*CWSAPResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+CWSAP")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWSAPResp)
+	r.SSID = p.string()
+	r.Password = p.string()
+	r.Channel = p.int()
+	r.ECN = p.int()
+	r.MaxConn = p.int()
+	r.Hidden = p.bool()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWSAP?")
+	}
+	return r, nil
+}
+
+// CWDHCP represents the AT+CWDHCP command.
+type CWDHCP struct {
+	Enable bool
+	Mode   int
+}
+
+// MinVersion returns the minimum firmware version that supports CWDHCP.
+func (CWDHCP) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CWDHCP=...
+func (c CWDHCP) Args() []any {
+	return args(boolArg(c.Enable), c.Mode)
+}
+
+// Set executes AT+CWDHCP=...
+func (c CWDHCP) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CWDHCP=", c.Args()...)
+}
+
+// CWDHCPResp represents the response to the AT+CWDHCP? query.
+type CWDHCPResp struct {
+	State int
+}
+
+// Query executes AT+CWDHCP?
+func (CWDHCP) Query(d *espat.Device) (*CWDHCPResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+CWDHCP")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWDHCPResp)
+	r.State = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWDHCP?")
+	}
+	return r, nil
+}
+
+// CWHOSTNAME represents the AT+CWHOSTNAME command.
+type CWHOSTNAME struct {
+	Hostname string
+}
+
+// MinVersion returns the minimum firmware version that supports CWHOSTNAME.
+func (CWHOSTNAME) MinVersion() Version { return Version{1, 5, 0} }
+
+// Args returns the arguments of AT+CWHOSTNAME=...
+func (c CWHOSTNAME) Args() []any {
+	return args(c.Hostname)
+}
+
+// Set executes AT+CWHOSTNAME=...
+func (c CWHOSTNAME) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 5, 0}, "+CWHOSTNAME=", c.Args()...)
+}
+
+// CWHOSTNAMEResp represents the response to the AT+CWHOSTNAME? query.
+type CWHOSTNAMEResp struct {
+	Hostname string
+}
+
+// Query executes AT+CWHOSTNAME?
+func (CWHOSTNAME) Query(d *espat.Device) (*CWHOSTNAMEResp, error) {
+	p, err := query(d, Version{1, 5, 0}, "+CWHOSTNAME")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CWHOSTNAMEResp)
+	r.Hostname = p.string()
+	if p.err {
+		return nil, espat.ParseError(d, "+CWHOSTNAME?")
+	}
+	return r, nil
+}
+
+// CIPMUX represents the AT+CIPMUX command.
+type CIPMUX struct {
+	Mode bool
+}
+
+// MinVersion returns the minimum firmware version that supports CIPMUX.
+func (CIPMUX) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CIPMUX=...
+func (c CIPMUX) Args() []any {
+	return args(boolArg(c.Mode))
+}
+
+// Set executes AT+CIPMUX=...
+func (c CIPMUX) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CIPMUX=", c.Args()...)
+}
+
+// CIPMUXResp represents the response to the AT+CIPMUX? query.
+type CIPMUXResp struct {
+	Mode bool
+}
+
+// Query executes AT+CIPMUX?
+func (CIPMUX) Query(d *espat.Device) (*CIPMUXResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+CIPMUX")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CIPMUXResp)
+	r.Mode = p.bool()
+	if p.err {
+		return nil, espat.ParseError(d, "+CIPMUX?")
+	}
+	return r, nil
+}
+
+// CIPRECVMODE represents the AT+CIPRECVMODE command.
+type CIPRECVMODE struct {
+	Passive bool
+}
+
+// MinVersion returns the minimum firmware version that supports CIPRECVMODE.
+func (CIPRECVMODE) MinVersion() Version { return Version{1, 7, 0} }
+
+// Args returns the arguments of AT+CIPRECVMODE=...
+func (c CIPRECVMODE) Args() []any {
+	return args(boolArg(c.Passive))
+}
+
+// Set executes AT+CIPRECVMODE=...
+func (c CIPRECVMODE) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 7, 0}, "+CIPRECVMODE=", c.Args()...)
+}
+
+// CIPRECVMODEResp represents the response to the AT+CIPRECVMODE? query.
+type CIPRECVMODEResp struct {
+	Passive bool
+}
+
+// Query executes AT+CIPRECVMODE?
+func (CIPRECVMODE) Query(d *espat.Device) (*CIPRECVMODEResp, error) {
+	p, err := query(d, Version{1, 7, 0}, "+CIPRECVMODE")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CIPRECVMODEResp)
+	r.Passive = p.bool()
+	if p.err {
+		return nil, espat.ParseError(d, "+CIPRECVMODE?")
+	}
+	return r, nil
+}
+
+// CIPV6 represents the AT+CIPV6 command.
+type CIPV6 struct {
+	Enable bool
+}
+
+// MinVersion returns the minimum firmware version that supports CIPV6.
+func (CIPV6) MinVersion() Version { return Version{2, 0, 0} }
+
+// Args returns the arguments of AT+CIPV6=...
+func (c CIPV6) Args() []any {
+	return args(boolArg(c.Enable))
+}
+
+// Set executes AT+CIPV6=...
+func (c CIPV6) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 0, 0}, "+CIPV6=", c.Args()...)
+}
+
+// CIPV6Resp represents the response to the AT+CIPV6? query.
+type CIPV6Resp struct {
+	Enable bool
+}
+
+// Query executes AT+CIPV6?
+func (CIPV6) Query(d *espat.Device) (*CIPV6Resp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+CIPV6")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CIPV6Resp)
+	r.Enable = p.bool()
+	if p.err {
+		return nil, espat.ParseError(d, "+CIPV6?")
+	}
+	return r, nil
+}
+
+// CIPDNS represents the AT+CIPDNS command.
+type CIPDNS struct {
+	Enable bool
+	DNS1   string
+	DNS2   string
+	DNS3   string
+}
+
+// MinVersion returns the minimum firmware version that supports CIPDNS.
+func (CIPDNS) MinVersion() Version { return Version{2, 0, 0} }
+
+// Args returns the arguments of AT+CIPDNS=...
+func (c CIPDNS) Args() []any {
+	return args(boolArg(c.Enable), espat.Opt(c.DNS1), espat.Opt(c.DNS2), espat.Opt(c.DNS3))
+}
+
+// Set executes AT+CIPDNS=...
+func (c CIPDNS) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 0, 0}, "+CIPDNS=", c.Args()...)
+}
+
+// CIPDNSResp represents the response to the AT+CIPDNS? query.
+type CIPDNSResp struct {
+	Enable bool
+	DNS1   string
+	DNS2   string
+	DNS3   string
+}
+
+// Query executes AT+CIPDNS?
+func (CIPDNS) Query(d *espat.Device) (*CIPDNSResp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+CIPDNS")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CIPDNSResp)
+	r.Enable = p.bool()
+	r.DNS1 = p.string()
+	r.DNS2 = p.string()
+	r.DNS3 = p.string()
+	if p.err {
+		return nil, espat.ParseError(d, "+CIPDNS?")
+	}
+	return r, nil
+}
+
+// CIPSNTPCFG represents the AT+CIPSNTPCFG command.
+type CIPSNTPCFG struct {
+	Enable   bool
+	Timezone int
+	Server1  string
+	Server2  string
+	Server3  string
+}
+
+// MinVersion returns the minimum firmware version that supports CIPSNTPCFG.
+func (CIPSNTPCFG) MinVersion() Version { return Version{2, 0, 0} }
+
+// Args returns the arguments of AT+CIPSNTPCFG=...
+func (c CIPSNTPCFG) Args() []any {
+	return args(boolArg(c.Enable), c.Timezone, espat.Opt(c.Server1), espat.Opt(c.Server2), espat.Opt(c.Server3))
+}
+
+// Set executes AT+CIPSNTPCFG=...
+func (c CIPSNTPCFG) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 0, 0}, "+CIPSNTPCFG=", c.Args()...)
+}
+
+// CIPSNTPCFGResp represents the response to the AT+CIPSNTPCFG? query.
+type CIPSNTPCFGResp struct {
+	Enable   bool
+	Timezone int
+	Server1  string
+	Server2  string
+	Server3  string
+}
+
+// Query executes AT+CIPSNTPCFG?
+func (CIPSNTPCFG) Query(d *espat.Device) (*CIPSNTPCFGResp, error) {
+	p, err := query(d, Version{2, 0, 0}, "+CIPSNTPCFG")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CIPSNTPCFGResp)
+	r.Enable = p.bool()
+	r.Timezone = p.int()
+	r.Server1 = p.string()
+	r.Server2 = p.string()
+	r.Server3 = p.string()
+	if p.err {
+		return nil, espat.ParseError(d, "+CIPSNTPCFG?")
+	}
+	return r, nil
+}
+
+// CIPSTARTEX represents the AT+CIPSTARTEX command.
+type CIPSTARTEX struct {
+	Type       string
+	RemoteHost string
+	RemotePort int
+	LocalPort  int
+	KeepAlive  int
+}
+
+// MinVersion returns the minimum firmware version that supports CIPSTARTEX.
+func (CIPSTARTEX) MinVersion() Version { return Version{2, 1, 0} }
+
+// Args returns the arguments of AT+CIPSTARTEX=...
+func (c CIPSTARTEX) Args() []any {
+	return args(c.Type, c.RemoteHost, c.RemotePort, espat.Opt(c.LocalPort), espat.Opt(c.KeepAlive))
+}
+
+// Set executes AT+CIPSTARTEX=...
+func (c CIPSTARTEX) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{2, 1, 0}, "+CIPSTARTEX=", c.Args()...)
+}
+
+// CIPSERVERMAXCONN represents the AT+CIPSERVERMAXCONN command.
+type CIPSERVERMAXCONN struct {
+	Num int
+}
+
+// MinVersion returns the minimum firmware version that supports CIPSERVERMAXCONN.
+func (CIPSERVERMAXCONN) MinVersion() Version { return Version{1, 0, 0} }
+
+// Args returns the arguments of AT+CIPSERVERMAXCONN=...
+func (c CIPSERVERMAXCONN) Args() []any {
+	return args(c.Num)
+}
+
+// Set executes AT+CIPSERVERMAXCONN=...
+func (c CIPSERVERMAXCONN) Set(d *espat.Device) (*espat.Response, error) {
+	return cmd(d, Version{1, 0, 0}, "+CIPSERVERMAXCONN=", c.Args()...)
+}
+
+// CIPSERVERMAXCONNResp represents the response to the AT+CIPSERVERMAXCONN? query.
+type CIPSERVERMAXCONNResp struct {
+	Num int
+}
+
+// Query executes AT+CIPSERVERMAXCONN?
+func (CIPSERVERMAXCONN) Query(d *espat.Device) (*CIPSERVERMAXCONNResp, error) {
+	p, err := query(d, Version{1, 0, 0}, "+CIPSERVERMAXCONN")
+	if p == nil {
+		return nil, err
+	}
+	r := new(CIPSERVERMAXCONNResp)
+	r.Num = p.int()
+	if p.err {
+		return nil, espat.ParseError(d, "+CIPSERVERMAXCONN?")
+	}
+	return r, nil
+}
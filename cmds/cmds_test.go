@@ -0,0 +1,106 @@
+package cmds
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/internal/esptest"
+)
+
+func TestGenerated(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "cmds_gen.go")
+	if msg, err := exec.Command("go", "run", "gen.go", "-o", out).CombinedOutput(); err != nil {
+		t.Fatalf("go run gen.go: %v\n%s", err, msg)
+	}
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("cmds_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("cmds_gen.go doesn't match cmds.txt, run go generate")
+	}
+}
+
+var parseVersionTests = []struct {
+	s  string
+	v  Version
+	ok bool
+}{
+	{"2.2.0.0", Version{2, 2, 0}, true},
+	{"1.7.4", Version{1, 7, 4}, true},
+	{"3.10.1.0-dev", Version{3, 10, 1}, true},
+	{"2.2", Version{}, false},
+	{"", Version{}, false},
+	{"2.x.0", Version{2, 0, 0}, false},
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, tc := range parseVersionTests {
+		v, ok := ParseVersion(tc.s)
+		if ok != tc.ok || ok && v != tc.v {
+			t.Errorf("ParseVersion(%q) = %v, %v; want %v, %v", tc.s, v, ok, tc.v, tc.ok)
+		}
+	}
+}
+
+// newDevice returns the initialized device with the given firmware version
+// connected to the emulated ESP-AT module that answers the commands using the
+// script.
+func newDevice(t *testing.T, version string, script esptest.Script) *espat.Device {
+	script["ATE0"] = "\r\nOK\r\n"
+	script["AT+SYSLOG=1"] = "\r\nOK\r\n"
+	script["AT+GMR"] = "AT version:" + version + "\r\n\r\nOK\r\n"
+	_, r, w := esptest.New(t, "esp", script)
+	d := espat.NewDevice("esp", r, w)
+	if err := d.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestUnsupported(t *testing.T) {
+	d := newDevice(t, "1.7.4.0(May 11 2020 19:13:04)", esptest.Script{
+		`AT+CWMODE?`: "+CWMODE:1\r\n\r\nOK\r\n",
+	})
+	_, err := CWSTATE{}.Query(d)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("CWSTATE.Query: %v; want %v", err, ErrUnsupported)
+	}
+	_, err = CWRECONNCFG{Interval: 1, RepeatCount: 10}.Set(d)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("CWRECONNCFG.Set: %v; want %v", err, ErrUnsupported)
+	}
+	r, err := CWMODE{}.Query(d)
+	if err != nil || r == nil || r.Mode != 1 {
+		t.Errorf("CWMODE.Query: %v, %v; want {1}, nil", r, err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	d := newDevice(t, "2.2.0.0(c6fa6bf - ESP32 - Jul  2 2021 06:44:05)", esptest.Script{
+		`AT+CWJAP?`:   "No AP\r\n\r\nOK\r\n",
+		`AT+CWSTATE?`: "+CWSTATE:2,\"a\"\r\n\r\nOK\r\n",
+		`AT+SYSRAM?`:  "+SYSRAM:x,2\r\n\r\nOK\r\n",
+	})
+	jap, err := CWJAP{}.Query(d)
+	if jap != nil || err != nil {
+		t.Errorf("CWJAP.Query: %v, %v; want nil, nil", jap, err)
+	}
+	st, err := CWSTATE{}.Query(d)
+	if err != nil || st == nil || *st != (CWSTATEResp{2, "a"}) {
+		t.Errorf("CWSTATE.Query: %v, %v; want {2 a}, nil", st, err)
+	}
+	_, err = SYSRAM{}.Query(d)
+	if !errors.Is(err, espat.ErrParse) {
+		t.Errorf("SYSRAM.Query: %v; want %v", err, espat.ErrParse)
+	}
+}
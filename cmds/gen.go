@@ -0,0 +1,216 @@
+//go:build ignore
+
+// Gen generates cmds_gen.go from the command catalog in cmds.txt.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+)
+
+type field struct {
+	name string
+	typ  string
+	opt  bool
+}
+
+type command struct {
+	name  string
+	ver   [3]int
+	set   bool
+	query bool
+	exec  bool
+	args  []field
+	resp  []field
+}
+
+func parseFields(ln int, list []string) []field {
+	fields := make([]field, 0, len(list))
+	for _, f := range list {
+		name, typ, ok := strings.Cut(f, ":")
+		if !ok {
+			log.Fatalf("cmds.txt:%d: bad field %s", ln, f)
+		}
+		opt := strings.HasSuffix(typ, "?")
+		typ = strings.TrimSuffix(typ, "?")
+		switch typ {
+		case "string", "int", "bool":
+		default:
+			log.Fatalf("cmds.txt:%d: unknown type %s", ln, typ)
+		}
+		fields = append(fields, field{name, typ, opt})
+	}
+	return fields
+}
+
+func parse(name string) []*command {
+	f, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	var (
+		cmds []*command
+		cmd  *command
+		ln   int
+	)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ln++
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if cmd == nil {
+				log.Fatalf("cmds.txt:%d: arguments without command", ln)
+			}
+			switch words[0] {
+			case "set":
+				cmd.args = parseFields(ln, words[1:])
+			case "query":
+				cmd.resp = parseFields(ln, words[1:])
+			default:
+				log.Fatalf("cmds.txt:%d: unknown section %s", ln, words[0])
+			}
+			continue
+		}
+		if len(words) != 3 {
+			log.Fatalf("cmds.txt:%d: bad command line", ln)
+		}
+		cmd = &command{name: words[0]}
+		if _, err := fmt.Sscanf(
+			words[1], "%d.%d.%d", &cmd.ver[0], &cmd.ver[1], &cmd.ver[2],
+		); err != nil {
+			log.Fatalf("cmds.txt:%d: bad version: %v", ln, err)
+		}
+		for _, op := range strings.Split(words[2], ",") {
+			switch op {
+			case "set":
+				cmd.set = true
+			case "query":
+				cmd.query = true
+			case "exec":
+				cmd.exec = true
+			default:
+				log.Fatalf("cmds.txt:%d: unknown operation %s", ln, op)
+			}
+		}
+		cmds = append(cmds, cmd)
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return cmds
+}
+
+func argExpr(f field) string {
+	switch {
+	case f.opt && (f.typ == "string" || f.typ == "int"):
+		return "espat.Opt(c." + f.name + ")"
+	case f.opt && f.typ == "bool":
+		return "optBool(c." + f.name + ")"
+	case f.typ == "bool":
+		return "boolArg(c." + f.name + ")"
+	}
+	return "c." + f.name
+}
+
+func generate(w *bytes.Buffer, cmds []*command) {
+	fmt.Fprintln(w, "// Code generated by gen.go from cmds.txt; DO NOT EDIT.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "package cmds")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `import "github.com/embeddedgo/espat"`)
+	for _, c := range cmds {
+		typ := strings.ReplaceAll(c.name, "_", "")
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "// %s represents the AT+%s command.\n", typ, c.name)
+		if len(c.args) == 0 {
+			fmt.Fprintf(w, "type %s struct{}\n", typ)
+		} else {
+			fmt.Fprintf(w, "type %s struct {\n", typ)
+			for _, f := range c.args {
+				fmt.Fprintf(w, "\t%s %s\n", f.name, f.typ)
+			}
+			fmt.Fprintln(w, "}")
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "// MinVersion returns the minimum firmware version that supports %s.\n", typ)
+		ver := fmt.Sprintf("Version{%d, %d, %d}", c.ver[0], c.ver[1], c.ver[2])
+		fmt.Fprintf(w, "func (%s) MinVersion() Version { return %s }\n", typ, ver)
+		if c.set {
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "// Args returns the arguments of AT+%s=...\n", c.name)
+			fmt.Fprintf(w, "func (c %s) Args() []any {\n", typ)
+			fmt.Fprint(w, "\treturn args(")
+			for i, f := range c.args {
+				if i != 0 {
+					fmt.Fprint(w, ", ")
+				}
+				fmt.Fprint(w, argExpr(f))
+			}
+			fmt.Fprintln(w, ")")
+			fmt.Fprintln(w, "}")
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "// Set executes AT+%s=...\n", c.name)
+			fmt.Fprintf(w, "func (c %s) Set(d *espat.Device) (*espat.Response, error) {\n", typ)
+			fmt.Fprintf(w, "\treturn cmd(d, %s, \"+%s=\", c.Args()...)\n", ver, c.name)
+			fmt.Fprintln(w, "}")
+		}
+		if c.query {
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "// %sResp represents the response to the AT+%s? query.\n", typ, c.name)
+			fmt.Fprintf(w, "type %sResp struct {\n", typ)
+			for _, f := range c.resp {
+				fmt.Fprintf(w, "\t%s %s\n", f.name, f.typ)
+			}
+			fmt.Fprintln(w, "}")
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "// Query executes AT+%s?\n", c.name)
+			fmt.Fprintf(w, "func (%s) Query(d *espat.Device) (*%sResp, error) {\n", typ, typ)
+			fmt.Fprintf(w, "\tp, err := query(d, %s, \"+%s\")\n", ver, c.name)
+			fmt.Fprintln(w, "\tif p == nil {\n\t\treturn nil, err\n\t}")
+			fmt.Fprintf(w, "\tr := new(%sResp)\n", typ)
+			for _, f := range c.resp {
+				fmt.Fprintf(w, "\tr.%s = p.%s()\n", f.name, f.typ)
+			}
+			fmt.Fprintln(w, "\tif p.err {")
+			fmt.Fprintf(w, "\t\treturn nil, espat.ParseError(d, \"+%s?\")\n", c.name)
+			fmt.Fprintln(w, "\t}")
+			fmt.Fprintln(w, "\treturn r, nil")
+			fmt.Fprintln(w, "}")
+		}
+		if c.exec {
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "// Exec executes AT+%s.\n", c.name)
+			fmt.Fprintf(w, "func (%s) Exec(d *espat.Device) (*espat.Response, error) {\n", typ)
+			fmt.Fprintf(w, "\treturn cmd(d, %s, \"+%s\")\n", ver, c.name)
+			fmt.Fprintln(w, "}")
+		}
+	}
+}
+
+func main() {
+	out := flag.String("o", "cmds_gen.go", "output file")
+	flag.Parse()
+	var buf bytes.Buffer
+	generate(&buf, parse("cmds.txt"))
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
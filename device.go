@@ -3,6 +3,7 @@ package espat
 import (
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,14 +13,23 @@ type Device struct {
 	cmdq     chan *cmd
 	cmdx     sync.Mutex
 	w        io.Writer
+	t        Transport
 	receiver receiver
+	stats    stats
+	sleep    sleepState
+	initOpts []InitOption
+	fw       atomic.Pointer[firmware]
+	dialq    chan struct{}
 }
 
 // NewDevice returns a driver for ESP-AT device available via r and w. It also
 // starts required background goroutines. You must call Init method before use
-// the returned device.
+// the returned device. If r implements the Transport interface it is also
+// used as the device transport (see Transport).
 func NewDevice(name string, r io.Reader, w io.Writer) *Device {
 	d := &Device{name: name, cmdq: make(chan *cmd, 3), w: w}
+	d.dialq = make(chan struct{}, 1)
+	d.t, _ = r.(Transport)
 	d.cmdx.Lock() // to delay Init(true), will be unlocked by receiverLoop
 	receiverInit(&d.receiver)
 	go receiverLoop(d, r)
@@ -58,13 +68,34 @@ func (d *Device) SetServer(en bool) {
 // Init initailizes the device to the known state using the following commands:
 //
 //	ATE0
+//	AT+GMR
 //	AT+SYSLOG=1
 //
+// The AT+GMR response is used to detect the legacy firmware (see Legacy) that
+// doesn't support AT+SYSLOG so it is skipped in such case.
+//
 // If reset is true (recomended) it resets the device and waits for the ready
-// state (2 second max.) before executing the above commands.
-func (d *Device) Init(reset bool) error {
+// state (2 second max.) before executing the above commands. The additional
+// configuration commands described by opts are executed after the above ones
+// (see WithSysMsg, WithSysStore, WithSysMsgFilter). They replace the options
+// of the previous Init call.
+func (d *Device) Init(reset bool, opts ...InitOption) error {
+	return d.init(reset, opts)
+}
+
+// Reinit works like Init but uses the options of the previous Init call. It
+// can be used to restore the device after an error or the hard reset.
+func (d *Device) Reinit(reset bool) error {
+	d.cmdx.Lock()
+	opts := d.initOpts
+	d.cmdx.Unlock()
+	return d.init(reset, opts)
+}
+
+func (d *Device) init(reset bool, opts []InitOption) error {
 	if reset {
 		d.cmdx.Lock()
+		d.sleep.mode.Store(int32(NoSleep)) // reset wakes up the device
 		timeout := time.After(50 * time.Millisecond)
 	emptying:
 		for {
@@ -76,7 +107,7 @@ func (d *Device) Init(reset bool) error {
 			}
 		}
 		d.cmdx.Unlock()
-		if _, err := d.Cmd("+RST"); err != nil {
+		if _, err := d.CmdTimeout(2*time.Second, "+RST"); err != nil {
 			return err
 		}
 		timeout = time.After(2 * time.Second)
@@ -93,14 +124,34 @@ func (d *Device) Init(reset bool) error {
 			}
 		}
 	}
-	if _, err := d.Cmd("E0"); err != nil {
+	d.cmdx.Lock()
+	d.sleep.mode.Store(int32(NoSleep))
+	d.sleep.reinit.Store(false)
+	d.initOpts = opts
+	d.receiver.sysmsg.Store(0)
+	err := d.setup(d.exec)
+	d.cmdx.Unlock()
+	return err
+}
+
+// setup executes the initialization commands using the provided command
+// execution function.
+func (d *Device) setup(cmd func(string, ...any) (*Response, error)) error {
+	if _, err := cmd("E0"); err != nil {
 		return err
 	}
-	if _, err := d.Cmd("+SYSLOG=1"); err != nil {
+	resp, err := cmd("+GMR")
+	if err != nil {
 		return err
 	}
-	return nil
-
+	fw := detectFirmware(resp)
+	d.fw.Store(fw)
+	if !fw.legacy {
+		if _, err := cmd("+SYSLOG=1"); err != nil {
+			return err
+		}
+	}
+	return d.applyInitOptions(cmd)
 }
 
 // Lock locks the device. Device should be locked before use UnsafeCmd, Write,
@@ -115,35 +166,73 @@ func (d *Device) Unlock() {
 }
 
 // Cmd executes an AT command. Name should be a command name without the AT
-// prefix (e.g. "+GMR" instead of "AT+GMR"). Args may be of type string, int or
-// nil. The first argument can be also of type []byte and in a such case it may
-// be used as a receive buffer (for example the CIPRECVDATA command may read
-// data into it but is also allowed to discard all or part of received data if
-// the buffer was missing or too small). CmdStr, CmdInt, CmdConn can be used
-// instead of Cmd if the response type is known in advance.
+// prefix (e.g. "+GMR" instead of "AT+GMR"). Args may be of type string, int,
+// int64, uint64 or nil. The first argument can be also of type []byte and in
+// a such case it may be used as a receive buffer (for example the CIPRECVDATA
+// command may read data into it but is also allowed to discard all or part of
+// received data if the buffer was missing or too small). CmdStr, CmdInt,
+// CmdConn can be used instead of Cmd if the response type is known in advance.
 func (d *Device) Cmd(name string, args ...any) (resp *Response, err error) {
-	c := &cmd{name: name, args: args}
+	return d.CmdTimeout(0, name, args...)
+}
+
+// CmdTimeout is like Cmd but returns ErrTimeout if the response isn't received
+// within the timeout. The timeout is counted from the moment the command is
+// sent to the device. Timeout <= 0 means no timeout. The late response to the
+// timed out command is discarded. The next command is not sent to the device
+// until the late response is received or the device restarts (the ready
+// message is received) so it can also fail with ErrTimeout.
+func (d *Device) CmdTimeout(timeout time.Duration, name string, args ...any) (resp *Response, err error) {
+	c := &cmd{name: name, args: args, timeout: timeout}
 	c.ready.Lock()
 	d.cmdx.Lock()
-	d.cmdq <- c
+	if err = d.wake(); err == nil {
+		d.cmdq <- c
+	}
 	d.cmdx.Unlock()
-	c.ready.Lock()
-	if c.err != nil {
-		err = &Error{d.name, name, c.err}
+	if err == nil {
+		c.ready.Lock()
+		err = c.err
 	}
-	return &c.resp, err
+	return &c.resp, d.cmdErr(name, err)
 }
 
 // UnsafeCmd is like Cmd but intended to be used with a locked device.
 func (d *Device) UnsafeCmd(name string, args ...any) (resp *Response, err error) {
+	return d.UnsafeCmdTimeout(0, name, args...)
+}
+
+// UnsafeCmdTimeout is like CmdTimeout but intended to be used with a locked
+// device.
+func (d *Device) UnsafeCmdTimeout(timeout time.Duration, name string, args ...any) (resp *Response, err error) {
+	if err = d.wake(); err != nil {
+		return new(Response), d.cmdErr(name, err)
+	}
+	c := &cmd{name: name, args: args, timeout: timeout}
+	c.ready.Lock()
+	d.cmdq <- c
+	c.ready.Lock()
+	return &c.resp, d.cmdErr(name, c.err)
+}
+
+// exec executes a command on the locked device. It doesn't check the device
+// sleep state.
+func (d *Device) exec(name string, args ...any) (resp *Response, err error) {
 	c := &cmd{name: name, args: args}
 	c.ready.Lock()
 	d.cmdq <- c
 	c.ready.Lock()
-	if c.err != nil {
-		err = &Error{d.name, name, c.err}
+	return &c.resp, d.cmdErr(name, c.err)
+}
+
+func (d *Device) cmdErr(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		return e
 	}
-	return &c.resp, err
+	return &Error{d.name, name, err}
 }
 
 // CmdStr provides a convenient way to execute a command when a string response
@@ -167,6 +256,33 @@ func (d *Device) CmdConn(name string, args ...any) (*Conn, error) {
 	return resp.Conn, err
 }
 
+// Query executes the query command (name must end with '?', e.g. "+CWMODE?")
+// and returns the arguments of the first response line with the command name
+// prefix (see Response.Args). It returns the ErrParse error if the line has
+// less than minArgs arguments.
+func (d *Device) Query(name string, minArgs int) ([]string, error) {
+	resp, err := d.Cmd(name)
+	if err != nil {
+		return nil, err
+	}
+	args := resp.Args(name[:len(name)-1])
+	if len(args) < minArgs {
+		return nil, ParseError(d, name)
+	}
+	return args, nil
+}
+
+// Opt returns nil if v is the zero value, otherwise it returns v. It can be
+// used to pass the optional arguments to Cmd (nil is sent as the empty
+// argument).
+func Opt[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
 // UnsafeWrite works like io.Writer Write method. Device must be locked and
 // ready for at least len(p) bytes of data.
 func (d *Device) UnsafeWrite(p []byte) (int, error) {
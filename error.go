@@ -36,10 +36,18 @@ type timeoutError struct{}
 func (e timeoutError) Error() string { return "timeout" }
 func (e timeoutError) Timeout() bool { return true }
 
+// ParseError returns the Error with Err set to ErrParse. It can be used to
+// report the unexpected response to the command executed on d.
+func ParseError(d *Device, cmd string) error {
+	return &Error{d.name, cmd, ErrParse}
+}
+
 // Errors that may be returned in the Error.Err field.
 var (
-	ErrTimeout  = &timeoutError{}
-	ErrParse    = errors.New("parse")
-	ErrArgType  = errors.New("argument type")
-	ErrUnkConn  = errors.New("unknown connection")
+	ErrTimeout     = &timeoutError{}
+	ErrParse       = errors.New("parse")
+	ErrArgType     = errors.New("argument type")
+	ErrUnkConn     = errors.New("unknown connection")
+	ErrSleeping    = errors.New("sleeping")
+	ErrNoTransport = errors.New("no transport")
 )
@@ -0,0 +1,57 @@
+//go:build linux
+
+// Espd is a daemon that allows many local processes to share one ESP-AT
+// device connected to the serial port.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/espd"
+	"github.com/embeddedgo/espat/transport/uart"
+)
+
+func fatalErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func main() {
+	var (
+		fb = flag.Int("b", 115200, "baudrate")
+		fr = flag.Bool("r", false, "reboot the ESP-AT device first")
+		fs = flag.String("s", "/run/espd.sock", "Unix socket path")
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Println("  espd [options] UART_DEVICE")
+		fmt.Println()
+		fmt.Println("Options:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	port, err := uart.Open(flag.Arg(0), *fb)
+	fatalErr(err)
+
+	dev := espat.NewDevice("esp0", port, port)
+	fatalErr(dev.Init(*fr))
+	_, err = dev.Cmd("+CIPMUX=1") // required by espd.Serve
+	fatalErr(err)
+
+	os.Remove(*fs)
+	ls, err := net.Listen("unix", *fs)
+	fatalErr(err)
+	fatalErr(espd.Serve(ls, dev))
+}
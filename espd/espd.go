@@ -0,0 +1,394 @@
+// Package espd allows many processes to share one ESP-AT device.
+//
+// The daemon owns the device (e.g. the serial port it is connected to) and
+// serves the AT command protocol to the local clients over a Unix socket. The
+// client side of the socket is an ordinary *espat.Device (see Dial) so the
+// code that uses the espn or espnet packages runs unchanged against the
+// daemon.
+//
+// The daemon executes the client commands one by one and routes the
+// connection related messages (CONNECT, +IPD, CLOSED) to the client that owns
+// the connection: the one that opened it or the one that started the server
+// that accepted it. The other asynchronous messages (e.g. "WIFI GOT IP") are
+// sent to all clients. A client can't use the connections owned by other
+// clients. The connections and the server owned by the disconnected client
+// are closed.
+//
+// The commands that would break the other clients or the daemon itself (e.g.
+// AT+RST, AT+UART_CUR, AT+SYSMSG, AT+CIPMODE, AT+CIPRECVMODE=, ATE1) are
+// rejected with ERROR. The device should be configured by the daemon before
+// Serve is called. AT+CIPMUX=1 is required to share the connections between
+// clients so the client AT+CIPMUX=1 command is answered with OK without
+// executing it and AT+CIPMUX=0 is rejected.
+//
+// The daemon executes AT+CIPSTARTEX as AT+CIPSTART with the link ID chosen by
+// itself so the opened connection can't be confused with the one accepted by
+// the server at the same time.
+//
+// BUG: The connection details reported by the +LINK_CONN message aren't
+// passed to the clients.
+package espd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/embeddedgo/espat"
+)
+
+// maxLinks is the number of link IDs routed by the daemon.
+const maxLinks = 10
+
+// promptTimeout limits the time the device waits for the client data after
+// the data prompt.
+var promptTimeout = 5 * time.Second
+
+// dial describes the pending dial.
+type dial struct {
+	id int              // link ID of the dialed connection
+	ch chan *espat.Conn // receives the connection
+}
+
+type server struct {
+	d        *espat.Device
+	mu       sync.Mutex
+	clients  map[*client]struct{}
+	owner    [maxLinks]*client
+	listener *client    // the client that started the server
+	dial     *dial      // pending dial
+	dmu      sync.Mutex // serializes dials
+}
+
+type client struct {
+	s   *server
+	c   net.Conn
+	r   *bufio.Reader
+	wmu sync.Mutex
+}
+
+// Serve accepts the client connections on the listener l and serves them
+// using the device d. It takes over the d.Async and d.Server channels so
+// Serve can be called only once for the device. Serve always returns a
+// non-nil error returned by l.Accept.
+func Serve(l net.Listener, d *espat.Device) error {
+	s := &server{d: d, clients: make(map[*client]struct{})}
+	d.SetServer(true)
+	go s.accept()
+	go s.async()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		cl := &client{s: s, c: c, r: bufio.NewReader(c)}
+		s.mu.Lock()
+		s.clients[cl] = struct{}{}
+		s.mu.Unlock()
+		go cl.serve()
+	}
+}
+
+// Dial connects to the daemon listening on the Unix socket at path and
+// returns the device that represents the shared ESP-AT device. Use
+// Init(false) to initialize the returned device.
+func Dial(name, path string) (*espat.Device, error) {
+	c, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return espat.NewDevice(name, c, c), nil
+}
+
+// accept routes the connections reported by the device.
+func (s *server) accept() {
+	for conn := range s.d.Server() {
+		s.mu.Lock()
+		if s.dial != nil && conn.ID == s.dial.id {
+			s.dial.ch <- conn
+			s.dial = nil
+			s.mu.Unlock()
+			continue
+		}
+		c := s.listener
+		if c != nil {
+			s.owner[linkIndex(conn.ID)] = c
+		}
+		s.mu.Unlock()
+		if c == nil {
+			// nobody listens, reject the connection
+			go func(conn *espat.Conn) {
+				for range conn.Ch {
+				}
+			}(conn)
+			go s.d.Cmd(closeCmd(conn.ID))
+			continue
+		}
+		c.write(linkMsg(conn.ID, "CONNECT"))
+		go s.forward(c, conn)
+	}
+}
+
+// async broadcasts the asynchronous messages to all clients.
+func (s *server) async() {
+	for msg := range s.d.Async() {
+		if msg.Err != nil || msg.Str == "" {
+			continue
+		}
+		s.mu.Lock()
+		clients := make([]*client, 0, len(s.clients))
+		for c := range s.clients {
+			clients = append(clients, c)
+		}
+		s.mu.Unlock()
+		for _, c := range clients {
+			c.write(msg.Str + "\r\n")
+		}
+	}
+}
+
+// forward passes the data received from the connection to its owner.
+func (s *server) forward(c *client, conn *espat.Conn) {
+	for data := range conn.Ch {
+		c.write(ipdMsg(conn.ID, data))
+	}
+	ci := linkIndex(conn.ID)
+	s.mu.Lock()
+	if s.owner[ci] == c {
+		s.owner[ci] = nil
+	}
+	s.mu.Unlock()
+	c.write(linkMsg(conn.ID, "CLOSED"))
+}
+
+func (c *client) write(s string) error {
+	c.wmu.Lock()
+	_, err := io.WriteString(c.c, s)
+	c.wmu.Unlock()
+	return err
+}
+
+func (c *client) serve() {
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 2 || line[:2] != "AT" {
+			continue
+		}
+		if err = c.exec(line[2:]); err != nil {
+			break
+		}
+	}
+	c.c.Close()
+	c.close()
+}
+
+// close releases the resources owned by the disconnected client.
+func (c *client) close() {
+	s := c.s
+	var ids []int
+	s.mu.Lock()
+	delete(s.clients, c)
+	for ci, o := range s.owner {
+		if o == c {
+			ids = append(ids, ci)
+		}
+	}
+	listener := s.listener == c
+	if listener {
+		s.listener = nil
+	}
+	s.mu.Unlock()
+	if listener {
+		name := "+CIPSERVER=0,1"
+		if s.d.Legacy() {
+			name = "+CIPSERVER=0"
+		}
+		s.d.Cmd(name)
+	}
+	mux := s.d.MultiConn()
+	for _, ci := range ids {
+		id := -1
+		if mux {
+			id = ci
+		}
+		s.d.Cmd(closeCmd(id))
+	}
+}
+
+// exec executes the command received from the client. It returns an error only
+// if the client connection failed.
+func (c *client) exec(line string) error {
+	s := c.s
+	name, args := parseCmd(line)
+	if rejected(name) {
+		return c.write(errMsg(nil))
+	}
+	mux := s.d.MultiConn()
+	switch name {
+	case "+CIPMUX=":
+		if mux && len(args) == 1 && args[0] == 1 {
+			return c.write(okMsg(""))
+		}
+		return c.write(errMsg(nil))
+	case "+CIPSEND=", "+CIPSENDL=", "+CIPSENDEX=", "+CIPCLOSE=", "+CIPRECVDATA=":
+		if mux && !c.owns(args) {
+			return c.write(errMsg(nil))
+		}
+	}
+	switch name {
+	case "+CIPSTART=", "+CIPSTARTEX=":
+		return c.dial(name, args)
+	case "+CIPRECVDATA=":
+		return c.recvData(args)
+	}
+	if n := promptLen(name, args, mux); n >= 0 {
+		return c.prompt(name, args, n)
+	}
+	resp, err := s.d.Cmd(name, args...)
+	if err != nil {
+		return c.write(errMsg(err))
+	}
+	if name == "+CIPSERVER=" && len(args) != 0 {
+		s.mu.Lock()
+		if args[0] == 1 {
+			s.listener = c
+		} else if s.listener == c {
+			s.listener = nil
+		}
+		s.mu.Unlock()
+	}
+	return c.write(okMsg(resp.Str))
+}
+
+// owns reports whether the client owns the connection passed as the first
+// argument of the command.
+func (c *client) owns(args []any) bool {
+	if len(args) == 0 {
+		return false
+	}
+	id, ok := args[0].(int)
+	if !ok || uint(id) >= maxLinks {
+		return false
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	return c.s.owner[id] == c
+}
+
+func (c *client) dial(name string, args []any) error {
+	s := c.s
+	s.dmu.Lock()
+	defer s.dmu.Unlock()
+	dl := &dial{id: -1, ch: make(chan *espat.Conn, 1)}
+	if s.d.MultiConn() {
+		if name == "+CIPSTARTEX=" {
+			if dl.id = s.d.FreeLink(); dl.id < 0 {
+				return c.write(errMsg(nil))
+			}
+			name, args = "+CIPSTART=", append([]any{dl.id}, args...)
+		} else if len(args) != 0 {
+			if id, ok := args[0].(int); ok {
+				dl.id = id
+			}
+		}
+		if uint(dl.id) >= maxLinks {
+			return c.write(errMsg(nil))
+		}
+	}
+	s.mu.Lock()
+	s.dial = dl
+	s.mu.Unlock()
+	resp, err := s.d.Cmd(name, args...)
+	var conn *espat.Conn
+	if err == nil {
+		// The CONNECT message precedes OK so it should be already handled.
+		select {
+		case conn = <-dl.ch:
+		case <-time.After(time.Second):
+			err = espat.ErrTimeout
+		}
+	}
+	s.mu.Lock()
+	s.dial = nil
+	if conn != nil {
+		s.owner[linkIndex(conn.ID)] = c
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return c.write(errMsg(err))
+	}
+	err = c.write(linkMsg(conn.ID, "CONNECT") + okMsg(resp.Str))
+	go s.forward(c, conn)
+	return err
+}
+
+func (c *client) recvData(args []any) error {
+	n := -1
+	if len(args) != 0 {
+		n, _ = args[len(args)-1].(int)
+	}
+	if n <= 0 {
+		return c.write(errMsg(nil))
+	}
+	buf := make([]byte, n)
+	resp, err := c.s.d.Cmd("+CIPRECVDATA=", append([]any{buf}, args...)...)
+	if err != nil || resp.Int <= 0 {
+		return c.write(errMsg(err))
+	}
+	n = resp.Int
+	return c.write("+CIPRECVDATA:" + strconv.Itoa(n) + "," + string(buf[:n]) +
+		"\r\nOK\r\n")
+}
+
+// prompt executes the command that reads n bytes of data after the data
+// prompt.
+func (c *client) prompt(name string, args []any, n int) error {
+	d := c.s.d
+	d.Lock()
+	defer d.Unlock()
+	if _, err := d.UnsafeCmd(name, args...); err != nil {
+		return c.write(errMsg(err))
+	}
+	werr := c.write("\r\nOK\r\n\r\n>\r\n")
+	data := make([]byte, n)
+	var rerr error
+	if werr == nil {
+		// The device waits for n bytes so in case of error the rest of
+		// data is filled with zeros.
+		c.c.SetReadDeadline(time.Now().Add(promptTimeout))
+		_, rerr = io.ReadFull(c.r, data)
+		c.c.SetReadDeadline(time.Time{})
+	}
+	if _, err := d.UnsafeWrite(data); err != nil {
+		return err
+	}
+	_, err := d.UnsafeCmd("")
+	if werr != nil {
+		return werr
+	}
+	if rerr != nil {
+		return rerr
+	}
+	if name[:8] != "+CIPSEND" {
+		if err != nil {
+			return c.write(errMsg(err))
+		}
+		return c.write(okMsg(""))
+	}
+	switch {
+	case err == nil:
+		return c.write("\r\nSEND OK\r\n")
+	case errors.Is(err, espat.ErrTimeout):
+		return c.write("\r\nSEND FAIL\r\n")
+	}
+	return c.write(errMsg(err))
+}
@@ -0,0 +1,255 @@
+package espd
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/espn"
+	"github.com/embeddedgo/espat/espsys"
+	"github.com/embeddedgo/espat/internal/esptest"
+)
+
+// serve runs the daemon for the emulated device and returns the socket path.
+func serve(t *testing.T, script esptest.Script) string {
+	_, r, w := esptest.New(t, "esp", script)
+	d := espat.NewDevice("esp", r, w)
+	if _, err := d.Cmd("+CIPMUX=1"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "espd.sock")
+	ls, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ls.Close() })
+	go Serve(ls, d)
+	return path
+}
+
+func TestServe(t *testing.T) {
+	path := serve(t, esptest.Script{
+		"AT+CIPMUX=1":                   "\r\nOK\r\n",
+		"AT+GMR":                        "AT version:2.2.0.0\r\n\r\nOK\r\n",
+		`AT+CIPSTART=0,"TCP","host",80`: "0,CONNECT\r\n\r\nOK\r\n+IPD,0,5:hello\r\n",
+		"AT+CIPSEND=0,3":                "\r\nOK\r\n\r\n>\r\nRecv 3 bytes\r\n\r\nSEND OK\r\n",
+		"AT+CIPCLOSE=0":                 "0,CLOSED\r\n\r\nOK\r\n",
+	})
+	c, err := Dial("client", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Cmd("+GMR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Str != "AT version:2.2.0.0\n" {
+		t.Errorf("+GMR: %q", resp.Str)
+	}
+	if _, err = c.Cmd("+RST"); err == nil {
+		t.Error("+RST: no error")
+	}
+	if _, err = c.Cmd("+CIPMUX=0"); err == nil {
+		t.Error("+CIPMUX=0: no error")
+	}
+	if _, err = c.Cmd("+CIPMUX=1"); err != nil {
+		t.Errorf("+CIPMUX=1: %v", err)
+	}
+	if !c.MultiConn() {
+		t.Error("+CIPMUX=1: multiple connection mode not set")
+	}
+	conn, err := c.CmdConn("+CIPSTARTEX=", "TCP", "host", 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn == nil || conn.ID != 0 {
+		t.Fatalf("+CIPSTARTEX: %+v", conn)
+	}
+	select {
+	case data := <-conn.Ch:
+		if !reflect.DeepEqual(data, []byte("hello")) {
+			t.Errorf("+IPD: %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("+IPD: timeout")
+	}
+	c.Lock()
+	_, err = c.UnsafeCmd("+CIPSEND=", 0, 3)
+	if err == nil {
+		_, err = c.UnsafeWriteString("abc")
+	}
+	if err == nil {
+		_, err = c.UnsafeCmd("")
+	}
+	c.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Cmd("+CIPCLOSE=", 0); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-conn.Ch:
+		if ok {
+			t.Error("CLOSED: channel not closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CLOSED: timeout")
+	}
+}
+
+func TestRecvData(t *testing.T) {
+	path := serve(t, esptest.Script{
+		"AT+CIPMUX=1":                   "\r\nOK\r\n",
+		`AT+CIPSTART=0,"TCP","host",80`: "0,CONNECT\r\n\r\nOK\r\n",
+		"AT+CIPRECVDATA=0,8":            "+CIPRECVDATA:5,hello\r\nOK\r\n",
+		"AT+GMR":                        "AT version:2.2.0.0\r\n\r\nOK\r\n",
+	})
+	c, err := Dial("client", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.CmdConn("+CIPSTARTEX=", "TCP", "host", 80); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	resp, err := c.Cmd("+CIPRECVDATA=", buf, 0, len(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:resp.Int]) != "hello" {
+		t.Errorf("+CIPRECVDATA: %q", buf[:resp.Int])
+	}
+	// The next command gets its own response.
+	resp, err = c.Cmd("+GMR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Str != "AT version:2.2.0.0\n" {
+		t.Errorf("+GMR: %q", resp.Str)
+	}
+}
+
+func TestDialDuringAccept(t *testing.T) {
+	path := serve(t, esptest.Script{
+		"AT+CIPMUX=1":       "\r\nOK\r\n",
+		"AT+CIPSERVER=1,80": "\r\nOK\r\n",
+		// The server accepts the connection before the dialed one is
+		// reported.
+		`AT+CIPSTART=0,"TCP","host",80`: "1,CONNECT\r\n0,CONNECT\r\n\r\nOK\r\n",
+		"AT+CIPSERVER=0,1":              "\r\nOK\r\n",
+		"AT+CIPCLOSE=0":                 "0,CLOSED\r\n\r\nOK\r\n",
+		"AT+CIPCLOSE=1":                 "1,CLOSED\r\n\r\nOK\r\n",
+	})
+	srv, err := Dial("server", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// espn works unchanged against the daemon (ListenDev sends AT+CIPMUX=1).
+	if _, err = espn.ListenDev(srv, "tcp", ":80"); err != nil {
+		t.Fatal(err)
+	}
+	cl, err := Dial("client", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := cl.CmdConn("+CIPSTARTEX=", "TCP", "host", 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn == nil || conn.ID != 0 {
+		t.Fatalf("+CIPSTARTEX: %+v", conn)
+	}
+	select {
+	case conn = <-srv.Server():
+		if conn.ID != 1 {
+			t.Errorf("accepted: ID %d", conn.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("accept: timeout")
+	}
+	if _, err = cl.Cmd("+CIPCLOSE=", 1); err == nil {
+		t.Error("+CIPCLOSE=1 by the client: no error")
+	}
+	if _, err = srv.Cmd("+CIPCLOSE=", 1); err != nil {
+		t.Error(err)
+	}
+	if _, err = cl.Cmd("+CIPCLOSE=", 0); err != nil {
+		t.Error(err)
+	}
+	if _, err = srv.Cmd("+CIPSERVER=0,1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMfgBinary(t *testing.T) {
+	path := serve(t, esptest.Script{
+		"AT+CIPMUX=1": "\r\nOK\r\n",
+		`AT+SYSMFG=1,"ns","key"`: "+SYSMFG:\"ns\",\"key\",10,4,a\nb\r\r\n" +
+			"\r\nOK\r\n",
+	})
+	c, err := Dial("client", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := espsys.MfgReadBinary(c, "ns", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a\nb\r" {
+		t.Errorf("MfgReadBinary: %q", data)
+	}
+}
+
+func TestPromptTimeout(t *testing.T) {
+	defer func(d time.Duration) { promptTimeout = d }(promptTimeout)
+	promptTimeout = 100 * time.Millisecond
+	path := serve(t, esptest.Script{
+		"AT+CIPMUX=1":                 "\r\nOK\r\n",
+		`AT+SYSMFG=2,"ns","key",10,3`: "\r\nOK\r\n\r\n>\r\nOK\r\n",
+		"AT+GMR":                      "AT version:2.2.0.0\r\n\r\nOK\r\n",
+	})
+	// The client that doesn't send the data after the prompt.
+	raw, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err = io.WriteString(raw, "AT+SYSMFG=2,\"ns\",\"key\",10,3\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	raw.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = io.ReadAll(raw); err != nil {
+		t.Fatalf("the client isn't disconnected: %v", err)
+	}
+	c, err := Dial("client", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Cmd("+GMR"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseCmd(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		name string
+		args []any
+	}{
+		{"+GMR", "+GMR", nil},
+		{"+CIPSEND=0,10", "+CIPSEND=", []any{0, 10}},
+		{`+CIPSTARTEX="TCP","a\"b",80`, "+CIPSTARTEX=", []any{"TCP", `a"b`, 80}},
+		{"+CWLAPOPT=1,,-50", "+CWLAPOPT=", []any{1, nil, -50}},
+		{"+X=0x10", "+X=0x10", nil},
+	} {
+		name, args := parseCmd(test.line)
+		if name != test.name || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%q: %q %v", test.line, name, args)
+		}
+	}
+}
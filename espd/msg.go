@@ -0,0 +1,174 @@
+package espd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/internal/sysmfg"
+)
+
+// parseCmd splits the command line (without the AT prefix) into the command
+// name and arguments. The quoted arguments are returned as strings, the
+// integer ones as ints and the empty ones as nil. If some argument is of
+// other kind the whole line is returned as the name.
+func parseCmd(line string) (name string, args []any) {
+	i := strings.IndexByte(line, '=')
+	if i < 0 {
+		return line, nil
+	}
+	name = line[:i+1]
+	s := line[i+1:]
+	for {
+		k := 0
+		if s != "" && s[0] == '"' {
+			for k = 1; k < len(s) && s[k] != '"'; k++ {
+				if s[k] == '\\' {
+					k++
+				}
+			}
+			if k >= len(s) {
+				return line, nil
+			}
+			args = append(args, espat.SplitArgs(s[:k+1])[0])
+			k++
+		} else {
+			for k < len(s) && s[k] != ',' {
+				k++
+			}
+			if k == 0 {
+				args = append(args, nil)
+			} else {
+				a, err := strconv.Atoi(s[:k])
+				if err != nil {
+					return line, nil
+				}
+				args = append(args, a)
+			}
+		}
+		if k == len(s) {
+			return name, args
+		}
+		if s[k] != ',' {
+			return line, nil
+		}
+		s = s[k+1:]
+	}
+}
+
+// rejected reports whether the command can't be executed on behalf of the
+// client.
+func rejected(name string) bool {
+	switch name {
+	case "E1", "+RST", "+RESTORE", "+GSLP=", "+SLEEP=", "+UART=", "+UART_CUR=",
+		"+UART_DEF=", "+SYSMSG=", "+CIPMODE=", "+CIPSEND", "+CIPRECVMODE=":
+		return true
+	}
+	return false
+}
+
+// promptLen returns the length of data that the command reads after the data
+// prompt or -1 if the command doesn't use the data prompt.
+func promptLen(name string, args []any, mux bool) int {
+	arg := func(i int) int {
+		if i >= len(args) {
+			return -1
+		}
+		n, ok := args[i].(int)
+		if !ok {
+			return -1
+		}
+		return n
+	}
+	switch name {
+	case "+CIPSEND=", "+CIPSENDL=", "+CIPSENDEX=":
+		if mux {
+			return arg(1)
+		}
+		return arg(0)
+	case "+SYSMFG=":
+		if arg(0) == 2 && (arg(3) == 9 || arg(3) == 10) {
+			return arg(4)
+		}
+	case "+SYSMSGFILTERCFG=":
+		if arg(0) == 1 && arg(1) >= 0 && arg(2) >= 0 {
+			return arg(1) + arg(2)
+		}
+	}
+	return -1
+}
+
+func linkIndex(id int) int {
+	if id < 0 {
+		return 0
+	}
+	return id
+}
+
+func closeCmd(id int) (name string) {
+	if id < 0 {
+		return "+CIPCLOSE"
+	}
+	return "+CIPCLOSE=" + strconv.Itoa(id)
+}
+
+// linkMsg returns the "<id>,CONNECT" or "<id>,CLOSED" message.
+func linkMsg(id int, s string) string {
+	if id < 0 {
+		return s + "\r\n"
+	}
+	return strconv.Itoa(id) + "," + s + "\r\n"
+}
+
+// ipdMsg returns the +IPD message that carries data in the active receive
+// mode or informs about the available data in the passive one (data == nil).
+func ipdMsg(id int, data []byte) string {
+	s := "+IPD,"
+	if id >= 0 {
+		s += strconv.Itoa(id) + ","
+	}
+	if data == nil {
+		return s + "0\r\n"
+	}
+	return s + strconv.Itoa(len(data)) + ":" + string(data) + "\r\n"
+}
+
+func crlf(s string) string {
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// respMsg converts the response string to the form sent by the device. The
+// +SYSMFG string and binary values are passed unchanged.
+func respMsg(str string) string {
+	var b strings.Builder
+	for str != "" {
+		if strings.HasPrefix(str, "+SYSMFG:") {
+			if k, m := sysmfg.Payload(str); k >= 0 && k+m <= len(str) {
+				b.WriteString(str[:k+m])
+				str = str[k+m:]
+			}
+		}
+		i := strings.IndexByte(str, '\n')
+		if i < 0 {
+			b.WriteString(str)
+			break
+		}
+		b.WriteString(str[:i])
+		b.WriteString("\r\n")
+		str = str[i+1:]
+	}
+	return b.String()
+}
+
+func okMsg(str string) string {
+	return respMsg(str) + "\r\nOK\r\n"
+}
+
+func errMsg(err error) string {
+	var e *espat.ErrorESP
+	if errors.As(err, &e) && e.Code != "socket" {
+		return crlf(e.Code) + "\r\nERROR\r\n"
+	}
+	return "\r\nERROR\r\n"
+}
@@ -5,7 +5,10 @@
 package espn
 
 import (
+	"errors"
 	"io"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/embeddedgo/espat"
@@ -19,6 +22,18 @@ type Conn struct {
 	adata         []byte
 	local         Addr
 	remote        Addr
+	opened        time.Time
+	sent          atomic.Uint64
+	recv          atomic.Uint64
+	sendFail      atomic.Uint32
+}
+
+// ConnStats contains the connection statistics.
+type ConnStats struct {
+	Opened   time.Time // time the connection was established
+	Sent     uint64    // number of bytes sent
+	Recv     uint64    // number of bytes received
+	SendFail uint      // number of failed send operations
 }
 
 // DialDev works like the net.Dial function.
@@ -27,19 +42,48 @@ func DialDev(d *espat.Device, network, address string) (*Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	conn, err := d.CmdConn("+CIPSTARTEX=", proto, host, port)
+	var conn *espat.Conn
+	switch {
+	case !d.Legacy():
+		conn, err = d.CmdConn("+CIPSTARTEX=", proto, host, port)
+	case d.MultiConn():
+		// The legacy firmware doesn't support CIPSTARTEX.
+		id := d.FreeLink()
+		if id < 0 {
+			return nil, &espat.Error{Dev: d.Name(), Cmd: "+CIPSTART=", Err: ErrNoFreeLink}
+		}
+		conn, err = d.CmdConn("+CIPSTART=", id, proto, host, port)
+	default:
+		conn, err = d.CmdConn("+CIPSTART=", proto, host, port)
+	}
 	if err != nil {
+		var e *espat.Error
+		if errors.As(err, &e) && d.FreeLinks() == 0 {
+			// ESP-AT reports the lack of free links as a generic ERROR.
+			if _, ok := e.Err.(*espat.ErrorESP); ok {
+				e.Err = ErrNoFreeLink
+			}
+		}
 		return nil, err
 	}
 	return newConn(conn)
 }
 
 func newConn(conn *espat.Conn) (*Conn, error) {
+	c := &Conn{conn: conn, readTimer: time.NewTimer(0), opened: time.Now()}
+	<-c.readTimer.C // unfortunately this is the only way to get a stopped timer
+	if link := conn.Link; link != nil {
+		net := linkNet(link.Proto)
+		c.local.net = net
+		c.local.hostPort = ":" + strconv.Itoa(link.LocalPort)
+		c.remote.net = net
+		c.remote.hostPort = joinHostPort(link.RemoteIP, link.RemotePort)
+		return c, nil
+	}
 	sas, err := getSockAddrs(conn.Dev)
 	if err != nil {
 		return nil, err
 	}
-	c := &Conn{conn: conn, readTimer: time.NewTimer(0)}
 	ci := conn.ID
 	if ci < 0 {
 		ci = 0
@@ -55,7 +99,6 @@ func newConn(conn *espat.Conn) (*Conn, error) {
 			break
 		}
 	}
-	<-c.readTimer.C // unfortunately this is the only way to get a stopped timer
 	return c, nil
 }
 
@@ -85,6 +128,7 @@ func (c *Conn) Read(p []byte) (n int, err error) {
 			if n != len(data) {
 				c.adata = data[n:]
 			}
+			c.recv.Add(uint64(n))
 			return
 		}
 	case <-c.readTimer.C: // timeout
@@ -100,6 +144,7 @@ func (c *Conn) Read(p []byte) (n int, err error) {
 	}
 	args[ai] = len(p)
 	n, err = c.conn.Dev.CmdInt("+CIPRECVDATA=", args[:ai+1]...)
+	c.recv.Add(uint64(n))
 	return
 }
 
@@ -117,12 +162,14 @@ func send(c *Conn, n int) (m int, err error) {
 			err = &espat.Error{c.conn.Dev.Name(), "read", espat.ErrTimeout}
 			return
 		}
-		args[ai+0] = -1
-		args[ai+1] = 0
-		args[ai+2] = to
-		_, err = c.conn.Dev.UnsafeCmd("+CIPTCPOPT=", args[:ai+3]...)
-		if err != nil {
-			return
+		if !c.conn.Dev.Legacy() { // legacy firmware doesn't support CIPTCPOPT
+			args[ai+0] = -1
+			args[ai+1] = 0
+			args[ai+2] = to
+			_, err = c.conn.Dev.UnsafeCmd("+CIPTCPOPT=", args[:ai+3]...)
+			if err != nil {
+				return
+			}
 		}
 	}
 	m = n
@@ -143,6 +190,11 @@ func (c *Conn) Write(p []byte) (n int, err error) {
 			m, err = c.conn.Dev.UnsafeWrite(p[:m])
 			if err == nil {
 				_, err = c.conn.Dev.UnsafeCmd("")
+				if err != nil {
+					c.sendFail.Add(1)
+				} else {
+					c.sent.Add(uint64(m))
+				}
 				n += m
 				p = p[m:]
 			}
@@ -164,6 +216,11 @@ func (c *Conn) WriteString(p string) (n int, err error) {
 			m, err = c.conn.Dev.UnsafeWriteString(p[:m])
 			if err == nil {
 				_, err = c.conn.Dev.UnsafeCmd("")
+				if err != nil {
+					c.sendFail.Add(1)
+				} else {
+					c.sent.Add(uint64(m))
+				}
 				n += m
 				p = p[m:]
 			}
@@ -225,6 +282,16 @@ func (c *Conn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+// Stats returns a snapshot of the connection statistics.
+func (c *Conn) Stats() ConnStats {
+	return ConnStats{
+		Opened:   c.opened,
+		Sent:     c.sent.Load(),
+		Recv:     c.recv.Load(),
+		SendFail: uint(c.sendFail.Load()),
+	}
+}
+
 // LocalAddr works like the net.Conn LocalAddr method.
 func (c *Conn) LocalAddr() *Addr {
 	return &c.local
@@ -0,0 +1,45 @@
+// Copyright 2023 The Embedded Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package espn
+
+import (
+	"context"
+	"errors"
+
+	"github.com/embeddedgo/espat"
+)
+
+// ErrNoFreeLink is returned in the espat.Error Err field if all connection
+// IDs of the device are in use.
+var ErrNoFreeLink = errors.New("no free link")
+
+// DialDevContext works like DialDev but if all connection IDs are in use it
+// waits until one of them is freed. The waiting dials are queued and served
+// one by one. If ctx is done before the connection is established the
+// ErrNoFreeLink error is returned (wrapped in espat.Error).
+func DialDevContext(ctx context.Context, d *espat.Device, network, address string) (*Conn, error) {
+	noFreeLink := &espat.Error{Dev: d.Name(), Cmd: "dial", Err: ErrNoFreeLink}
+	if d.AcquireDial(ctx) != nil {
+		return nil, noFreeLink
+	}
+	defer d.ReleaseDial()
+	for {
+		// Obtain the channel before checking the links to not miss any
+		// CLOSED message.
+		freed := d.LinkFreed()
+		if d.FreeLinks() != 0 {
+			c, err := DialDev(d, network, address)
+			if !errors.Is(err, ErrNoFreeLink) {
+				return c, err
+			}
+			// The link was taken by someone else in the meantime.
+		}
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, noFreeLink
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/embeddedgo/espat"
 	"github.com/embeddedgo/espat/espn"
+	"github.com/embeddedgo/espat/espwifi"
 	"github.com/ziutek/serial"
 )
 
@@ -45,19 +47,15 @@ func main() {
 	dev := espat.NewDevice("esp0", uart, uart)
 	fatalErr(dev.Init(true))
 
-waitForIP:
-	for {
-		select {
-		case msg := <-dev.Async():
-			fatalErr(msg.Err)
-			if msg.Str == "WIFI GOT IP" {
-				break waitForIP
-			}
-		case <-time.After(5 * time.Second):
-			fmt.Println("Cannot obtain an IP address: timeout.")
-			os.Exit(1)
-		}
+	// Wait for an IP address.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ipcfg, err := espwifi.WaitForIP(ctx, dev)
+	cancel()
+	if err != nil {
+		fmt.Println("Cannot obtain an IP address:", err)
+		os.Exit(1)
 	}
+	fmt.Println("IP address:", ipcfg.Addr)
 
 	ls, err := espn.ListenDev(dev, "tcp", os.Args[2])
 	fatalErr(err)
@@ -6,15 +6,19 @@ package espn
 
 import (
 	"errors"
+	"net"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/embeddedgo/espat"
 )
 
 type Listener struct {
-	d *espat.Device
-	a Addr
+	d      *espat.Device
+	a      Addr
+	closed chan struct{}
+	once   sync.Once
 }
 
 // ListenDev works like the net.Listen function.
@@ -37,19 +41,32 @@ func ListenDev(d *espat.Device, network, address string) (*Listener, error) {
 	}
 	ls := new(Listener)
 	ls.d = d
+	ls.closed = make(chan struct{})
 	ls.a.net = network
 	ls.a.hostPort = address
 	return ls, nil
 }
 
-// Accept works like the net.Listener Accept method.
+// Accept works like the net.Listener Accept method. It returns net.ErrClosed
+// if the listener is closed.
 func (ls *Listener) Accept() (*Conn, error) {
-	return newConn(<-ls.d.Server())
+	select {
+	case conn := <-ls.d.Server():
+		return newConn(conn)
+	case <-ls.closed:
+		return nil, net.ErrClosed
+	}
 }
 
-// Close works like the net.Listener Close method.
+// Close works like the net.Listener Close method. It also unblocks the pending
+// Accept calls.
 func (ls *Listener) Close() error {
-	_, err := ls.d.Cmd("+CIPSERVER=0,1")
+	ls.once.Do(func() { close(ls.closed) })
+	cmd := "+CIPSERVER=0,1"
+	if ls.d.Legacy() {
+		cmd = cmd[:len(cmd)-2] // legacy firmware doesn't close connections
+	}
+	_, err := ls.d.Cmd(cmd)
 	return err
 }
 
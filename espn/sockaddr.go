@@ -15,27 +15,15 @@ import (
 const maxConns = 10 // keep in sync with ../receiver.go
 
 func getSockAddrs(d *espat.Device) ([]string, error) {
-	cmdColon := "+CIPSTATUS:"
-	status, err := d.CmdStr(cmdColon[:len(cmdColon)-1])
+	resp, err := d.Cmd("+CIPSTATUS")
 	if err != nil {
 		return nil, err
 	}
 	ret := make([]string, 0, maxConns)
-	i := 0
-	for {
-		k := strings.Index(status[i:], cmdColon)
-		if k < 0 {
-			break
-		}
-		i += k + len(cmdColon)
-		k = strings.IndexByte(status[i:], '\n')
-		if k < 0 {
-			break
-		}
-		if sa := status[i : i+k]; len(sa) >= 2 {
+	for _, sa := range resp.Values("+CIPSTATUS") {
+		if len(sa) >= 2 {
 			ret = append(ret, sa)
 		}
-		i += k + 1
 	}
 	return ret, nil
 }
@@ -101,6 +89,24 @@ func parseSockAddr(sa string) (net, local, remote string, server bool) {
 	return
 }
 
+func linkNet(proto string) string {
+	switch proto {
+	case "TCP", "TCPv6", "SSL", "SSLv6":
+		return "tcp"
+	case "UDP", "UDPv6":
+		return "udp"
+	}
+	return ""
+}
+
+func joinHostPort(host string, port int) string {
+	p := strconv.Itoa(port)
+	if strings.IndexByte(host, ':') >= 0 {
+		return "[" + host + "]:" + p
+	}
+	return host + ":" + p
+}
+
 func splitHostPort(net, addr string) (proto, host, port string, pn int, err error) {
 	var proto6 string
 	switch net {
@@ -1,6 +1,7 @@
 package espnet
 
 import (
+	"context"
 	"net"
 	"time"
 
@@ -15,6 +16,16 @@ type Conn espn.Conn
 // DialDev works like net.Dial.
 func DialDev(d *espat.Device, network, address string) (*Conn, error) {
 	c, err := espn.DialDev(d, network, address)
+	return dialResult(c, network, err)
+}
+
+// DialDevContext works like espn.DialDevContext.
+func DialDevContext(ctx context.Context, d *espat.Device, network, address string) (*Conn, error) {
+	c, err := espn.DialDevContext(ctx, d, network, address)
+	return dialResult(c, network, err)
+}
+
+func dialResult(c *espn.Conn, network string, err error) (*Conn, error) {
 	if err != nil {
 		if _, ok := err.(*espat.Error); !ok {
 			s := err.Error()
@@ -69,6 +80,11 @@ func (c *Conn) SetDeadline(t time.Time) error {
 	return (*espn.Conn)(c).SetWriteDeadline(t)
 }
 
+// Stats returns a snapshot of the connection statistics.
+func (c *Conn) Stats() espn.ConnStats {
+	return (*espn.Conn)(c).Stats()
+}
+
 // LocalAddr implements the net.Conn LocalAddr method.
 func (c *Conn) LocalAddr() net.Addr {
 	return (*espn.Conn)(c).LocalAddr()
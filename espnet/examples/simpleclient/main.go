@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/embeddedgo/espat"
 	"github.com/embeddedgo/espat/espnet"
+	"github.com/embeddedgo/espat/espwifi"
 	"github.com/ziutek/serial"
 )
 
@@ -40,19 +42,15 @@ func main() {
 	dev := espat.NewDevice("esp0", uart, uart)
 	fatalErr(dev.Init(true))
 
-waitForIP:
-	for {
-		select {
-		case msg := <-dev.Async():
-			fatalErr(msg.Err)
-			if msg.Str == "WIFI GOT IP" {
-				break waitForIP
-			}
-		case <-time.After(5 * time.Second):
-			fmt.Println("Cannot obtain an IP address: timeout.")
-			os.Exit(1)
-		}
+	// Wait for an IP address.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ipcfg, err := espwifi.WaitForIP(ctx, dev)
+	cancel()
+	if err != nil {
+		fmt.Println("Cannot obtain an IP address:", err)
+		os.Exit(1)
 	}
+	fmt.Println("IP address:", ipcfg.Addr)
 
 	conn, err := espnet.DialDev(dev, "tcp", os.Args[2])
 	fatalErr(err)
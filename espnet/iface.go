@@ -0,0 +1,127 @@
+package espnet
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/espwifi"
+)
+
+// Interface represents the network interface of the ESP-AT device. The fields
+// have the same meaning as the net.Interface fields.
+type Interface struct {
+	Index        int
+	MTU          int
+	Name         string
+	HardwareAddr net.HardwareAddr
+	Flags        net.Flags
+
+	d     *espat.Device
+	iface espwifi.Iface
+}
+
+// Interfaces works like net.Interfaces for the ESP-AT device. It returns the
+// station and SoftAP interfaces enabled by the current Wi-Fi mode and the
+// Ethernet interface if supported by the device. The station interface is up
+// if it is connected to the AP. ESP-AT doesn't report the Ethernet link state
+// so the Ethernet interface is up if it has the IPv4 address.
+func Interfaces(d *espat.Device) ([]*Interface, error) {
+	mode, err := espwifi.GetMode(d)
+	if err != nil {
+		return nil, err
+	}
+	var ifs []*Interface
+	add := func(iface espwifi.Iface, up bool) error {
+		mac, err := espwifi.GetMAC(d, iface)
+		if err != nil {
+			return err
+		}
+		ifi := &Interface{d: d, iface: iface}
+		ifi.Index = len(ifs) + 1
+		ifi.MTU = 1500
+		ifi.Name = iface.String()
+		ifi.HardwareAddr = mac
+		ifi.Flags = net.FlagBroadcast | net.FlagMulticast
+		if up {
+			ifi.Flags |= net.FlagUp | net.FlagRunning
+		}
+		ifs = append(ifs, ifi)
+		return nil
+	}
+	if mode&espwifi.ModeStation != 0 {
+		st, err := espwifi.State(d)
+		if err != nil {
+			return nil, err
+		}
+		up := st.State == espwifi.Connected || st.State == espwifi.GotIP
+		if err = add(espwifi.IfaceStation, up); err != nil {
+			return nil, err
+		}
+	}
+	if mode&espwifi.ModeSoftAP != 0 {
+		if err = add(espwifi.IfaceSoftAP, true); err != nil {
+			return nil, err
+		}
+	}
+	// The Ethernet interface is optional. ESP-AT reports ERROR if it isn't
+	// supported.
+	cfg, err := espwifi.GetIP(d, espwifi.IfaceEthernet)
+	if err == nil {
+		up := cfg.Addr.IsValid() && !cfg.Addr.Addr().IsUnspecified()
+		err = add(espwifi.IfaceEthernet, up)
+	}
+	var e *espat.ErrorESP
+	if err != nil && !errors.As(err, &e) {
+		return nil, err
+	}
+	return ifs, nil
+}
+
+// Addrs works like the net.Interface Addrs method. It returns the IPv4 address
+// and the IPv6 addresses (if IPv6 is enabled using AT+CIPV6=1). The IPv6
+// addresses are reported with the /64 prefix.
+func (ifi *Interface) Addrs() ([]net.Addr, error) {
+	cfg, err := espwifi.GetIP(ifi.d, ifi.iface)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []net.Addr
+	if p := cfg.Addr; p.IsValid() && !p.Addr().IsUnspecified() {
+		addrs = append(addrs, &net.IPNet{
+			IP:   p.Addr().AsSlice(),
+			Mask: net.CIDRMask(p.Bits(), 32),
+		})
+	}
+	for _, a := range [...]netip.Addr{cfg.LinkLocal, cfg.Global} {
+		if a.IsValid() && !a.IsUnspecified() {
+			addrs = append(addrs, &net.IPNet{
+				IP:   a.AsSlice(),
+				Mask: net.CIDRMask(64, 128),
+			})
+		}
+	}
+	return addrs, nil
+}
+
+// InterfaceAddrs works like net.InterfaceAddrs for the ESP-AT device. It
+// returns the addresses of all interfaces that are up.
+func InterfaceAddrs(d *espat.Device) ([]net.Addr, error) {
+	ifs, err := Interfaces(d)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []net.Addr
+	for _, ifi := range ifs {
+		if ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+		a, err := ifi.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, a...)
+	}
+	return addrs, nil
+}
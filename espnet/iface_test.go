@@ -0,0 +1,106 @@
+package espnet
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/internal/esptest"
+)
+
+// newDevice returns the device connected to the emulated ESP-AT module that
+// answers the commands using the script.
+func newDevice(t *testing.T, script esptest.Script) *espat.Device {
+	_, r, w := esptest.New(t, "esp", script)
+	return espat.NewDevice("esp", r, w)
+}
+
+// ifaceScript returns the script of the device in the station+SoftAP mode
+// with the station disconnected. The Ethernet commands are added by tests.
+func ifaceScript(eth esptest.Script) esptest.Script {
+	script := esptest.Script{
+		"AT+CWMODE?":    "+CWMODE:3\r\n\r\nOK\r\n",
+		"AT+CWSTATE?":   "+CWSTATE:4,\"a\"\r\n\r\nOK\r\n",
+		"AT+CIPSTAMAC?": "+CIPSTAMAC:\"18:fe:34:00:00:01\"\r\n\r\nOK\r\n",
+		"AT+CIPAPMAC?":  "+CIPAPMAC:\"1a:fe:34:00:00:01\"\r\n\r\nOK\r\n",
+		"AT+CIPAP?":     "+CIPAP:ip:\"192.168.4.1\"\r\n+CIPAP:netmask:\"255.255.255.0\"\r\n\r\nOK\r\n",
+	}
+	for k, v := range eth {
+		script[k] = v
+	}
+	return script
+}
+
+type ifaceDesc struct {
+	name string
+	up   bool
+}
+
+func checkIfaces(t *testing.T, ifs []*Interface, want ...ifaceDesc) {
+	t.Helper()
+	if len(ifs) != len(want) {
+		t.Fatalf("%d interfaces, want %d", len(ifs), len(want))
+	}
+	for i, ifi := range ifs {
+		up := ifi.Flags&net.FlagUp != 0
+		if ifi.Index != i+1 || ifi.Name != want[i].name || up != want[i].up {
+			t.Errorf("%d: %+v, want %+v", i, ifi, want[i])
+		}
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	// Ethernet not supported.
+	d := newDevice(t, ifaceScript(esptest.Script{
+		"AT+CIPETH?": "\r\nERROR\r\n",
+	}))
+	ifs, err := Interfaces(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIfaces(t, ifs, ifaceDesc{"station", false}, ifaceDesc{"softap", true})
+	if mac := ifs[1].HardwareAddr.String(); mac != "1a:fe:34:00:00:01" {
+		t.Errorf("SoftAP MAC: %s", mac)
+	}
+	addrs, err := InterfaceAddrs(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0].String() != "192.168.4.1/24" {
+		t.Errorf("InterfaceAddrs: %v", addrs)
+	}
+}
+
+func TestInterfacesEthernet(t *testing.T) {
+	for _, test := range []struct {
+		ip string
+		up bool
+	}{
+		{"0.0.0.0", false},
+		{"192.168.1.10", true},
+	} {
+		d := newDevice(t, ifaceScript(esptest.Script{
+			"AT+CIPETH?":    "+CIPETH:ip:\"" + test.ip + "\"\r\n\r\nOK\r\n",
+			"AT+CIPETHMAC?": "+CIPETHMAC:\"1c:fe:34:00:00:01\"\r\n\r\nOK\r\n",
+		}))
+		ifs, err := Interfaces(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkIfaces(t, ifs, ifaceDesc{"station", false},
+			ifaceDesc{"softap", true}, ifaceDesc{"ethernet", test.up})
+	}
+}
+
+func TestInterfacesError(t *testing.T) {
+	// The malformed response is reported, not ignored.
+	d := newDevice(t, ifaceScript(esptest.Script{
+		"AT+CIPETH?":    "+CIPETH:ip:\"192.168.1.10\"\r\n\r\nOK\r\n",
+		"AT+CIPETHMAC?": "+CIPETHMAC:\"x\"\r\n\r\nOK\r\n",
+	}))
+	_, err := Interfaces(d)
+	if !errors.Is(err, espat.ErrParse) {
+		t.Errorf("Interfaces: %v; want %v", err, espat.ErrParse)
+	}
+}
@@ -25,8 +25,12 @@ func (ls *Listener) Addr() net.Addr {
 
 func (ls *Listener) Accept() (net.Conn, error) {
 	c, err := (*espn.Listener)(ls).Accept()
+	if err != nil {
+		a := ls.Addr()
+		return nil, &net.OpError{Op: "accept", Net: a.Network(), Addr: a, Err: err}
+	}
 	conn := (*Conn)(c)
-	return conn, netOpError(conn, "accept", err)
+	return conn, nil
 }
 
 func (ls *Listener) Close() error {
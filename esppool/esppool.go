@@ -0,0 +1,205 @@
+// Package esppool manages many ESP-AT devices as one network interface.
+//
+// A single ESP-AT device supports only a few concurrent connections (five in
+// case of the current firmware). The Pool distributes the connections among
+// many devices so the total number of connections is limited only by the
+// number of devices.
+package esppool
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/espn"
+	"github.com/embeddedgo/espat/espnet"
+)
+
+// ErrNoFreeLink is returned by Dial if all the healthy devices have all their
+// links in use.
+var ErrNoFreeLink = espn.ErrNoFreeLink
+
+// Status describes a device managed by the pool.
+type Status struct {
+	Dev       *espat.Device
+	Health    espat.HealthState // Healthy if the device has no monitor
+	FreeLinks int
+}
+
+type member struct {
+	d *espat.Device
+	m *espat.Monitor
+}
+
+// Pool is a set of ESP-AT devices. The zero value is an empty pool ready to
+// use.
+type Pool struct {
+	mu   sync.Mutex
+	devs []member
+}
+
+// Add adds the initialized device d to the pool. The optional monitor m is
+// used to check the device health. The devices that aren't healthy are
+// skipped by Dial and Listen.
+func (p *Pool) Add(d *espat.Device, m *espat.Monitor) {
+	p.mu.Lock()
+	p.devs = append(p.devs, member{d, m})
+	p.mu.Unlock()
+}
+
+// Remove removes d from the pool. The connections that use d are not
+// affected.
+func (p *Pool) Remove(d *espat.Device) {
+	p.mu.Lock()
+	for i, m := range p.devs {
+		if m.d == d {
+			p.devs = append(p.devs[:i], p.devs[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Status returns the current status of all devices in the pool.
+func (p *Pool) Status() []Status {
+	p.mu.Lock()
+	devs := append([]member(nil), p.devs...)
+	p.mu.Unlock()
+	sts := make([]Status, len(devs))
+	for i, m := range devs {
+		sts[i].Dev = m.d
+		if m.m != nil {
+			sts[i].Health = m.m.State()
+		}
+		sts[i].FreeLinks = m.d.FreeLinks()
+	}
+	return sts
+}
+
+// healthy returns the healthy devices sorted by the number of free links in
+// descending order.
+func (p *Pool) healthy() []Status {
+	sts := p.Status()
+	n := 0
+	for _, st := range sts {
+		if st.Health == espat.Healthy {
+			sts[n] = st
+			n++
+		}
+	}
+	sts = sts[:n]
+	sort.SliceStable(sts, func(i, j int) bool {
+		return sts[i].FreeLinks > sts[j].FreeLinks
+	})
+	return sts
+}
+
+// Dial works like net.Dial. It uses the healthy device with the largest
+// number of free links. If the dial fails the next device is tried.
+func (p *Pool) Dial(network, address string) (net.Conn, error) {
+	var err error
+	for _, st := range p.healthy() {
+		if st.FreeLinks == 0 {
+			break
+		}
+		var c *espnet.Conn
+		if c, err = espnet.DialDev(st.Dev, network, address); err == nil {
+			return c, nil
+		}
+	}
+	if err == nil {
+		err = &net.OpError{Op: "dial", Net: network, Err: ErrNoFreeLink}
+	}
+	return nil, err
+}
+
+// Listen works like net.Listen. It starts listening on all healthy devices
+// and returns the listener that accepts the connections from all of them. It
+// fails only if none of the devices can listen.
+func (p *Pool) Listen(network, address string) (net.Listener, error) {
+	ls := &Listener{
+		conns: make(chan accepted),
+		done:  make(chan struct{}),
+	}
+	var err error
+	for _, st := range p.healthy() {
+		var l *espnet.Listener
+		if l, err = espnet.ListenDev(st.Dev, network, address); err != nil {
+			continue
+		}
+		ls.ls = append(ls.ls, l)
+		go ls.accept(l)
+	}
+	if len(ls.ls) == 0 {
+		if err == nil {
+			err = &net.OpError{Op: "listen", Net: network, Err: errors.New("no healthy device")}
+		}
+		return nil, err
+	}
+	return ls, nil
+}
+
+type accepted struct {
+	c   net.Conn
+	err error
+}
+
+// Listener implements the net.Listener interface for many devices.
+type Listener struct {
+	ls    []*espnet.Listener
+	conns chan accepted
+	done  chan struct{}
+	once  sync.Once
+}
+
+// accept passes the connections accepted by l to the Accept method. It ends
+// when l is closed.
+func (ls *Listener) accept(l *espnet.Listener) {
+	for {
+		c, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		select {
+		case ls.conns <- accepted{c, err}:
+		case <-ls.done:
+			if err == nil {
+				c.Close()
+			}
+			return
+		}
+	}
+}
+
+// Accept implements the net.Listener Accept method.
+func (ls *Listener) Accept() (net.Conn, error) {
+	select {
+	case a := <-ls.conns:
+		return a.c, a.err
+	case <-ls.done:
+		return nil, &net.OpError{Op: "accept", Net: ls.Addr().Network(), Err: net.ErrClosed}
+	}
+}
+
+// Close implements the net.Listener Close method. It closes the listeners on
+// all devices and returns the first error encountered.
+func (ls *Listener) Close() error {
+	var err error
+	ls.once.Do(func() {
+		close(ls.done)
+		for _, l := range ls.ls {
+			if e := l.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+// Addr implements the net.Listener Addr method. It returns the address of the
+// listener on the first device.
+func (ls *Listener) Addr() net.Addr {
+	return ls.ls[0].Addr()
+}
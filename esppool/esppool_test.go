@@ -0,0 +1,133 @@
+package esppool
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/internal/esptest"
+)
+
+// newDevice returns the device connected to the emulated ESP-AT module that
+// answers the commands using the script.
+func newDevice(t *testing.T, name string, script esptest.Script) *espat.Device {
+	_, r, w := esptest.New(t, name, script)
+	return espat.NewDevice(name, r, w)
+}
+
+// cipstatus returns the AT+CIPSTATUS response that describes the connection
+// 0 to the remote ip:80.
+func cipstatus(ip string) string {
+	return "STATUS:3\r\n+CIPSTATUS:0,\"TCP\",\"" + ip + "\",80,50000,0\r\n\r\nOK\r\n"
+}
+
+func TestDial(t *testing.T) {
+	// a has one free link, b has five.
+	a := newDevice(t, "a", esptest.Script{
+		`AT+CIPSTARTEX="TCP","host",80`: "CONNECT\r\n\r\nOK\r\n",
+		"AT+CIPSTATUS":                  cipstatus("10.0.0.1"),
+	})
+	b := newDevice(t, "b", esptest.Script{
+		"AT+CIPMUX=1":                   "\r\nOK\r\n",
+		`AT+CIPSTARTEX="TCP","host",80`: "0,CONNECT\r\n\r\nOK\r\n",
+		"AT+CIPSTATUS":                  cipstatus("10.0.0.2"),
+	})
+	if _, err := b.Cmd("+CIPMUX=1"); err != nil {
+		t.Fatal(err)
+	}
+	var p Pool
+	p.Add(a, nil)
+	p.Add(b, nil)
+	c, err := p.Dial("tcp", "host:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.RemoteAddr().String(); s != "10.0.0.2:80" {
+		t.Errorf("the device with more free links not used: %s", s)
+	}
+}
+
+func TestDialFailover(t *testing.T) {
+	a := newDevice(t, "a", esptest.Script{
+		`AT+CIPSTARTEX="TCP","host",80`: "\r\nERROR\r\n",
+	})
+	b := newDevice(t, "b", esptest.Script{
+		`AT+CIPSTARTEX="TCP","host",80`: "CONNECT\r\n\r\nOK\r\n",
+		"AT+CIPSTATUS":                  cipstatus("10.0.0.2"),
+	})
+	var p Pool
+	p.Add(a, nil)
+	p.Add(b, nil)
+	c, err := p.Dial("tcp", "host:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.RemoteAddr().String(); s != "10.0.0.2:80" {
+		t.Errorf("remote address: %s", s)
+	}
+	// b has no free links now so only a is tried.
+	_, err = p.Dial("tcp", "host:80")
+	var e *espat.ErrorESP
+	if !errors.As(err, &e) {
+		t.Errorf("dial: %v; want ErrorESP", err)
+	}
+	p.Remove(a)
+	_, err = p.Dial("tcp", "host:80")
+	if !errors.Is(err, ErrNoFreeLink) {
+		t.Errorf("dial: %v; want %v", err, ErrNoFreeLink)
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	a := newDevice(t, "a", esptest.Script{
+		"AT+CIPMUX=1":       "\r\nOK\r\n",
+		"AT+CIPSERVER=1,80": "\r\nOK\r\n0,CONNECT\r\n",
+		"AT+CIPSTATUS":      cipstatus("10.0.0.3"),
+		"AT+CIPSERVER=0,1":  "\r\nOK\r\n",
+	})
+	var p Pool
+	p.Add(a, nil)
+	ls, err := p.Listen("tcp", ":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ls.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.RemoteAddr().String(); s != "10.0.0.3:80" {
+		t.Errorf("remote address: %s", s)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := ls.Accept()
+		done <- err
+	}()
+	if err = ls.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Accept: %v; want %v", err, net.ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept not unblocked by Close")
+	}
+	// The device listener is closed too.
+	done = make(chan error, 1)
+	go func() {
+		_, err := ls.(*Listener).ls[0].Accept()
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("device Accept: %v; want %v", err, net.ErrClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("device Accept not unblocked by Close")
+	}
+}
@@ -0,0 +1,3 @@
+// Package espsys provides typed access to the ESP-AT system information and
+// configuration commands.
+package espsys
@@ -0,0 +1,201 @@
+package espsys
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/cmds"
+)
+
+// RAM contains the heap information returned by AT+SYSRAM?.
+type RAM struct {
+	Free    int // current free heap size in bytes
+	MinFree int // minimum free heap size since boot in bytes
+}
+
+// GetRAM returns the current and the minimum free heap size.
+func GetRAM(d *espat.Device) (ram RAM, err error) {
+	r, err := cmds.SYSRAM{}.Query(d)
+	if err != nil {
+		return
+	}
+	if r == nil {
+		return ram, espat.ParseError(d, "+SYSRAM?")
+	}
+	return RAM{r.Free, r.MinFree}, nil
+}
+
+// Partition describes a flash partition returned by AT+SYSFLASH?.
+type Partition struct {
+	Name    string
+	Type    int
+	Subtype int
+	Addr    uint32
+	Size    uint32
+}
+
+// Partitions returns the user partitions in the flash.
+func Partitions(d *espat.Device) ([]Partition, error) {
+	const name = "+SYSFLASH?"
+	resp, err := d.Cmd(name)
+	if err != nil {
+		return nil, err
+	}
+	var parts []Partition
+	for _, v := range resp.Values("+SYSFLASH") {
+		args := espat.SplitArgs(v)
+		if len(args) < 5 {
+			return nil, espat.ParseError(d, name)
+		}
+		var (
+			p          Partition
+			addr, size uint64
+		)
+		p.Name = args[0]
+		p.Type, err = strconv.Atoi(args[1])
+		if err == nil {
+			p.Subtype, err = strconv.Atoi(args[2])
+		}
+		if err == nil {
+			addr, err = strconv.ParseUint(args[3], 0, 32)
+		}
+		if err == nil {
+			size, err = strconv.ParseUint(args[4], 0, 32)
+		}
+		if err != nil {
+			return nil, espat.ParseError(d, name)
+		}
+		p.Addr = uint32(addr)
+		p.Size = uint32(size)
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// Timestamp returns the local time of the device (AT+SYSTIMESTAMP?).
+func Timestamp(d *espat.Device) (time.Time, error) {
+	r, err := cmds.SYSTIMESTAMP{}.Query(d)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if r == nil {
+		return time.Time{}, espat.ParseError(d, "+SYSTIMESTAMP?")
+	}
+	return time.Unix(int64(r.Timestamp), 0), nil
+}
+
+// SetTimestamp sets the local time of the device (AT+SYSTIMESTAMP=).
+func SetTimestamp(d *espat.Device, t time.Time) error {
+	_, err := cmds.SYSTIMESTAMP{Timestamp: int(t.Unix())}.Set(d)
+	return err
+}
+
+// RFPower contains the RF TX power settings in 0.25 dBm units (Wi-Fi) or
+// as the power level indexes (Bluetooth LE). The Bluetooth LE fields are -1
+// if not supported by the device.
+type RFPower struct {
+	WiFi    int
+	BLEAdv  int
+	BLEScan int
+	BLEConn int
+}
+
+// GetRFPower returns the current RF TX power settings (AT+RFPOWER?).
+func GetRFPower(d *espat.Device) (p RFPower, err error) {
+	args, err := d.Query("+RFPOWER?", 1)
+	if err != nil {
+		return
+	}
+	v := [4]int{-1, -1, -1, -1}
+	for i := 0; i < len(args) && i < len(v); i++ {
+		if v[i], err = strconv.Atoi(args[i]); err != nil {
+			return p, espat.ParseError(d, "+RFPOWER?")
+		}
+	}
+	return RFPower{v[0], v[1], v[2], v[3]}, nil
+}
+
+// SetRFPower sets the RF TX power (AT+RFPOWER=). Only the Wi-Fi power is set
+// if any of the Bluetooth LE fields is negative.
+func SetRFPower(d *espat.Device, p RFPower) error {
+	var err error
+	if p.BLEAdv < 0 || p.BLEScan < 0 || p.BLEConn < 0 {
+		_, err = d.Cmd("+RFPOWER=", p.WiFi)
+	} else {
+		_, err = d.Cmd("+RFPOWER=", p.WiFi, p.BLEAdv, p.BLEScan, p.BLEConn)
+	}
+	return err
+}
+
+// Version contains the version information returned by AT+GMR.
+type Version struct {
+	AT          string // AT core version, e.g. "2.2.0.0(c6fa6bf - ESP32 - ...)"
+	SDK         string // SDK version, e.g. "v4.2.2-76-gefa6eca"
+	CompileTime string
+	Bin         string // firmware version, e.g. "2.2.0(WROOM-32)"
+}
+
+// GetVersion returns the firmware version information.
+func GetVersion(d *espat.Device) (v Version, err error) {
+	resp, err := d.Cmd("+GMR")
+	if err != nil {
+		return
+	}
+	for _, line := range resp.Values("") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key, val := line[:i], line[i+1:]
+		switch {
+		case strings.HasPrefix(key, "AT version"):
+			v.AT = val
+		case strings.HasPrefix(key, "SDK version"):
+			v.SDK = val
+		case strings.HasPrefix(key, "compile time"):
+			v.CompileTime = val
+		case strings.HasPrefix(key, "Bin version"):
+			v.Bin = val
+		}
+	}
+	if v.AT == "" {
+		err = espat.ParseError(d, "+GMR")
+	}
+	return
+}
+
+// CmdInfo describes an AT command supported by the device.
+type CmdInfo struct {
+	Name  string // command name, e.g. "AT+GMR"
+	Test  bool   // AT+<x>=?
+	Query bool   // AT+<x>?
+	Set   bool   // AT+<x>=...
+	Exec  bool   // AT+<x>
+}
+
+// Commands returns the list of commands supported by the device (AT+CMD?).
+func Commands(d *espat.Device) ([]CmdInfo, error) {
+	const name = "+CMD?"
+	resp, err := d.Cmd(name)
+	if err != nil {
+		return nil, err
+	}
+	vals := resp.Values("+CMD")
+	cmds := make([]CmdInfo, 0, len(vals))
+	for _, v := range vals {
+		args := espat.SplitArgs(v)
+		if len(args) < 6 {
+			return nil, espat.ParseError(d, name)
+		}
+		cmds = append(cmds, CmdInfo{
+			Name:  args[1],
+			Test:  args[2] == "1",
+			Query: args[3] == "1",
+			Set:   args[4] == "1",
+			Exec:  args[5] == "1",
+		})
+	}
+	return cmds, nil
+}
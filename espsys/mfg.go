@@ -0,0 +1,296 @@
+package espsys
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/embeddedgo/espat"
+)
+
+// MfgType represents the type of the value stored in the manufacturing NVS
+// partition (mfg_nvs).
+type MfgType int8
+
+const (
+	MfgU8     MfgType = 1
+	MfgI8     MfgType = 2
+	MfgU16    MfgType = 3
+	MfgI16    MfgType = 4
+	MfgU32    MfgType = 5
+	MfgI32    MfgType = 6
+	MfgU64    MfgType = 7
+	MfgI64    MfgType = 8
+	MfgString MfgType = 9
+	MfgBinary MfgType = 10
+)
+
+// ErrMfgType is returned by the typed readers in the espat.Error Err field if
+// the stored value is of the other type.
+var ErrMfgType = errors.New("mfg value type")
+
+// MfgKey describes a key stored in the manufacturing NVS partition.
+type MfgKey struct {
+	Namespace string
+	Key       string
+	Type      MfgType
+}
+
+// MfgNamespaces returns the list of namespaces in the manufacturing NVS
+// partition (AT+SYSMFG?).
+func MfgNamespaces(d *espat.Device) ([]string, error) {
+	resp, err := d.Cmd("+SYSMFG?")
+	if err != nil {
+		return nil, err
+	}
+	var nss []string
+	for _, v := range resp.Values("+SYSMFG") {
+		if args := espat.SplitArgs(v); len(args) != 0 {
+			nss = append(nss, args[0])
+		}
+	}
+	return nss, nil
+}
+
+// MfgKeys returns the list of keys in the namespace.
+func MfgKeys(d *espat.Device, namespace string) ([]MfgKey, error) {
+	const name = "+SYSMFG=1,"
+	resp, err := d.Cmd(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var keys []MfgKey
+	for _, v := range resp.Values("+SYSMFG") {
+		args := espat.SplitArgs(v)
+		if len(args) < 3 {
+			return nil, espat.ParseError(d, name)
+		}
+		typ, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, espat.ParseError(d, name)
+		}
+		keys = append(keys, MfgKey{args[0], args[1], MfgType(typ)})
+	}
+	return keys, nil
+}
+
+// MfgRead reads the value of the key. The integer values are returned as
+// int64 (the unsigned 64-bit value is returned as its two's complement), the
+// string values as string and the binary values as []byte.
+func MfgRead(d *espat.Device, namespace, key string) (any, error) {
+	_, v, err := mfgRead(d, namespace, key)
+	return v, err
+}
+
+// MfgReadInt reads the value of the key of any integer type. The MfgU64 value
+// is returned as its two's complement.
+func MfgReadInt(d *espat.Device, namespace, key string) (int64, error) {
+	typ, v, err := mfgRead(d, namespace, key)
+	if err != nil {
+		return 0, err
+	}
+	if typ < MfgU8 || typ > MfgI64 {
+		return 0, typeErr(d)
+	}
+	return v.(int64), nil
+}
+
+// MfgReadString reads the value of the MfgString key.
+func MfgReadString(d *espat.Device, namespace, key string) (string, error) {
+	typ, v, err := mfgRead(d, namespace, key)
+	if err != nil {
+		return "", err
+	}
+	if typ != MfgString {
+		return "", typeErr(d)
+	}
+	return v.(string), nil
+}
+
+// MfgReadBinary reads the value of the MfgBinary key.
+func MfgReadBinary(d *espat.Device, namespace, key string) ([]byte, error) {
+	typ, v, err := mfgRead(d, namespace, key)
+	if err != nil {
+		return nil, err
+	}
+	if typ != MfgBinary {
+		return nil, typeErr(d)
+	}
+	return v.([]byte), nil
+}
+
+func typeErr(d *espat.Device) error {
+	return &espat.Error{Dev: d.Name(), Cmd: "+SYSMFG=1,", Err: ErrMfgType}
+}
+
+func mfgRead(d *espat.Device, namespace, key string) (MfgType, any, error) {
+	const name = "+SYSMFG=1,"
+	resp, err := d.Cmd(name, namespace, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	// The string and binary values may contain '\n' so use the whole rest of
+	// the response.
+	i := strings.Index(resp.Str, "+SYSMFG:")
+	if i < 0 {
+		return 0, nil, espat.ParseError(d, name)
+	}
+	v := resp.Str[i+8:]
+	var (
+		args [4]string
+		n    int
+	)
+	for n < len(args) {
+		i := 0
+		if v != "" && v[0] == '"' {
+			i = 1
+			for i < len(v) && v[i] != '"' {
+				i++
+			}
+		}
+		for i < len(v) && v[i] != ',' {
+			i++
+		}
+		if i == len(v) {
+			return 0, nil, espat.ParseError(d, name)
+		}
+		args[n] = v[:i]
+		v = v[i+1:]
+		n++
+	}
+	typ, err := strconv.Atoi(args[2])
+	if err != nil {
+		return 0, nil, espat.ParseError(d, name)
+	}
+	switch MfgType(typ) {
+	case MfgString, MfgBinary:
+		m, err := strconv.Atoi(args[3])
+		if err != nil || m > len(v) {
+			return 0, nil, espat.ParseError(d, name)
+		}
+		if MfgType(typ) == MfgString {
+			return MfgString, v[:m], nil
+		}
+		return MfgBinary, []byte(v[:m]), nil
+	}
+	v = firstLine(v)
+	if MfgType(typ) == MfgU64 {
+		u, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, nil, espat.ParseError(d, name)
+		}
+		return MfgU64, int64(u), nil
+	}
+	x, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, nil, espat.ParseError(d, name)
+	}
+	return MfgType(typ), x, nil
+}
+
+func firstLine(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// MfgWriteInt writes the integer value of the given type. The MfgU64 value is
+// passed as its two's complement.
+func MfgWriteInt(d *espat.Device, namespace, key string, typ MfgType, val int64) error {
+	if typ < MfgU8 || typ > MfgI64 {
+		return &espat.Error{Dev: d.Name(), Cmd: "+SYSMFG=2,", Err: espat.ErrArgType}
+	}
+	var v any = val
+	if typ == MfgU64 {
+		v = uint64(val)
+	}
+	_, err := d.Cmd("+SYSMFG=2,", namespace, key, int(typ), v)
+	return err
+}
+
+// MfgWriteString writes the string value.
+func MfgWriteString(d *espat.Device, namespace, key, val string) error {
+	return mfgWrite(d, namespace, key, MfgString, len(val), func() (int, error) {
+		return d.UnsafeWriteString(val)
+	})
+}
+
+// MfgWriteBinary writes n bytes read from r as the binary value. The data is
+// streamed to the device through the data prompt. If r returns less than n
+// bytes the device is given zeros instead of the missing ones (it waits for
+// all n bytes) and the incomplete value is then erased.
+func MfgWriteBinary(d *espat.Device, namespace, key string, r io.Reader, n int) error {
+	return mfgWrite(d, namespace, key, MfgBinary, n, func() (int, error) {
+		var buf [128]byte
+		m := 0
+		for m < n {
+			k := len(buf)
+			if k > n-m {
+				k = n - m
+			}
+			k, err := io.ReadFull(r, buf[:k])
+			if err != nil {
+				return m, err
+			}
+			if k, err = d.UnsafeWrite(buf[:k]); err != nil {
+				return m + k, err
+			}
+			m += k
+		}
+		return m, nil
+	})
+}
+
+func mfgWrite(d *espat.Device, namespace, key string, typ MfgType, n int, write func() (int, error)) error {
+	const name = "+SYSMFG=2,"
+	d.Lock()
+	defer d.Unlock()
+	if _, err := d.UnsafeCmd(name, namespace, key, int(typ), n); err != nil {
+		return err
+	}
+	m, err := write()
+	if err != nil {
+		// Keep the device in sync: complete the data, read the final
+		// response and erase the incomplete value.
+		if pad(d, n-m) == nil {
+			if _, e := d.UnsafeCmd(""); e == nil {
+				d.UnsafeCmd("+SYSMFG=0,", namespace, key)
+			}
+		}
+		return &espat.Error{Dev: d.Name(), Cmd: name, Err: err}
+	}
+	_, err = d.UnsafeCmd("")
+	return err
+}
+
+// pad writes n zero bytes to the locked device.
+func pad(d *espat.Device, n int) error {
+	var zeros [128]byte
+	for n > 0 {
+		k := len(zeros)
+		if k > n {
+			k = n
+		}
+		if _, err := d.UnsafeWrite(zeros[:k]); err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
+
+// MfgErase erases the key in the namespace. If the key is empty the whole
+// namespace is erased.
+func MfgErase(d *espat.Device, namespace, key string) error {
+	var err error
+	if key == "" {
+		_, err = d.Cmd("+SYSMFG=0,", namespace)
+	} else {
+		_, err = d.Cmd("+SYSMFG=0,", namespace, key)
+	}
+	return err
+}
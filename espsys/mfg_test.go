@@ -0,0 +1,38 @@
+package espsys
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/internal/esptest"
+)
+
+func TestMfgWriteBinaryShort(t *testing.T) {
+	m, r, w := esptest.New(t, "esp", esptest.Script{
+		`AT+SYSMFG=2,"ns","key",10,4`: "\r\nOK\r\n\r\n>\r\nOK\r\n",
+		`AT+SYSMFG=0,"ns","key"`:      "\r\nOK\r\n",
+		`AT+SYSMFG?`:                  "+SYSMFG:\"ns\"\r\n\r\nOK\r\n",
+	})
+	d := espat.NewDevice("esp", r, w)
+	err := MfgWriteBinary(d, "ns", "key", strings.NewReader("ab"), 4)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("MfgWriteBinary: %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+	// The device is still in sync.
+	nss, err := MfgNamespaces(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nss, []string{"ns"}) {
+		t.Errorf("MfgNamespaces: %q", nss)
+	}
+	for _, want := range []string{`AT+SYSMFG=2,"ns","key",10,4`, `AT+SYSMFG=0,"ns","key"`, `AT+SYSMFG?`} {
+		if c := <-m.Cmds(); c != want {
+			t.Errorf("command: %q != %q", c, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Package espvar publishes the ESP-AT device statistics using the expvar
+// package. It is intended for host builds where the expvar HTTP handler is
+// available.
+package espvar
+
+import (
+	"expvar"
+
+	"github.com/embeddedgo/espat"
+)
+
+// Publish publishes the statistics of d as the expvar variable with the given
+// name. Every read of the variable takes a new snapshot using d.Stats. Like
+// expvar.Publish it panics if the name is already registered.
+func Publish(name string, d *espat.Device) {
+	expvar.Publish(name, expvar.Func(func() any {
+		st := d.Stats()
+		return map[string]any{
+			"Cmds":          st.Cmds,
+			"Errors":        st.Errors,
+			"Links":         st.Links,
+			"IPD":           st.IPD,
+			"SendFail":      st.SendFail,
+			"AsyncOverruns": st.AsyncOverruns,
+			"SinceLastResp": st.SinceLastResp().Seconds(),
+		}
+	}))
+}
@@ -0,0 +1,59 @@
+// Package espwifi provides typed access to the Wi-Fi functions of the ESP-AT
+// device: the station and SoftAP modes, scanning and the IP configuration.
+package espwifi
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/cmds"
+)
+
+// Mode represents the Wi-Fi mode (AT+CWMODE).
+type Mode int8
+
+const (
+	ModeNone    Mode = 0 // Wi-Fi RF disabled
+	ModeStation Mode = 1
+	ModeSoftAP  Mode = 2
+	ModeBoth    Mode = 3 // station + SoftAP
+)
+
+// GetMode returns the current Wi-Fi mode.
+func GetMode(d *espat.Device) (Mode, error) {
+	r, err := cmds.CWMODE{}.Query(d)
+	if err != nil {
+		return 0, err
+	}
+	if r == nil {
+		return 0, espat.ParseError(d, "+CWMODE?")
+	}
+	return Mode(r.Mode), nil
+}
+
+// SetMode sets the Wi-Fi mode.
+func SetMode(d *espat.Device, m Mode) error {
+	_, err := cmds.CWMODE{Mode: int(m)}.Set(d)
+	return err
+}
+
+// cmdTimeout returns the timeout for the command derived from the ctx
+// deadline or 0 if ctx has no deadline.
+func cmdTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		timeout = time.Nanosecond
+	}
+	return timeout
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,394 @@
+package espwifi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/internal/esptest"
+)
+
+// newDevice returns the device connected to the emulated ESP-AT module that
+// answers the commands using the script.
+func newDevice(t *testing.T, script esptest.Script) *espat.Device {
+	_, d := newModule(t, script)
+	return d
+}
+
+// newModule is like newDevice but also returns the module.
+func newModule(t *testing.T, script esptest.Script) (*esptest.Module, *espat.Device) {
+	m, r, w := esptest.New(t, "esp", script)
+	return m, espat.NewDevice("esp", r, w)
+}
+
+func TestJoin(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CWJAP="a","b"`:     "WIFI CONNECTED\r\nWIFI GOT IP\r\n\r\nOK\r\n",
+		`AT+CWJAP="a","c",,1`:  "+CWJAP:2\r\n\r\nERROR\r\n",
+		`AT+CWJAP="x",""`:      "+CWJAP:3\r\n\r\nERROR\r\n",
+		`AT+CWJAP="a","b",,,0`: "WIFI CONNECTED\r\nWIFI GOT IP\r\n\r\nOK\r\n",
+		`AT+CWSTATE?`:          "+CWSTATE:2,\"a\"\r\n\r\nOK\r\n",
+		`AT+CWJAP?`:            "+CWJAP:\"a\",\"ca:d7:19:d8:a6:44\",6,-55,0,0,0,0,0\r\n\r\nOK\r\n",
+	})
+	ctx := context.Background()
+	if err := Join(ctx, d, &Config{SSID: "a", Password: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Join(ctx, d, &Config{SSID: "a", Password: "c", PCIOnly: true}); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong password: %v", err)
+	}
+	if err := Join(ctx, d, &Config{SSID: "x"}); !errors.Is(err, ErrNoAP) {
+		t.Errorf("no AP: %v", err)
+	}
+	if err := Join(ctx, d, &Config{SSID: "a", Password: "b", NoReconnect: true}); err != nil {
+		t.Errorf("no reconnect: %v", err)
+	}
+	st, err := State(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Status{GotIP, "a", "ca:d7:19:d8:a6:44", 6, -55}
+	if *st != want {
+		t.Errorf("State: %+v", *st)
+	}
+}
+
+func TestJoinShortDeadline(t *testing.T) {
+	m, d := newModule(t, esptest.Script{
+		`AT+CWJAP="a","b",,,,,,3`: "",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	go func() {
+		<-m.Cmds()
+		// ESP-AT reports the result after the ctx deadline.
+		<-ctx.Done()
+		m.Write("+CWJAP:1\r\n\r\nERROR\r\n")
+	}()
+	if err := Join(ctx, d, &Config{SSID: "a", Password: "b"}); !errors.Is(err, ErrJoinTimeout) {
+		t.Errorf("Join: %v; want %v", err, ErrJoinTimeout)
+	}
+}
+
+func TestScan(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CWLAPOPT=0,2047,0,1023`: "\r\nOK\r\n",
+		`AT+CWLAP`: "+CWLAP:(3,\"a,b\",-60,\"ac:67:b2:00:00:01\",1,-1,-1,4,4,7,1)\r\n" +
+			"+CWLAP:(0,\"open\",-90,\"ac:67:b2:00:00:02\",6,-1,-1,0,0,3,0)\r\n\r\nOK\r\n",
+		`AT+CWLAPOPT=1,14,-80,1023`: "\r\nOK\r\n",
+		`AT+CWLAP="a,b",,1`:         "+CWLAP:(\"a,b\",-60,\"ac:67:b2:00:00:01\")\r\n\r\nOK\r\n",
+	})
+	ctx := context.Background()
+	aps, err := Scan(ctx, d, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AccessPoint{
+		{WPA2_PSK, "a,b", -60, "ac:67:b2:00:00:01", 1, -1, -1, CipherCCMP, CipherCCMP, PHY11b | PHY11g | PHY11n, true},
+		{Open, "open", -90, "ac:67:b2:00:00:02", 6, -1, -1, CipherNone, CipherNone, PHY11b | PHY11g, false},
+	}
+	if !reflect.DeepEqual(aps, want) {
+		t.Errorf("Scan:\n%+v\n%+v", aps, want)
+	}
+	aps, err = Scan(ctx, d, &ScanOptions{
+		SSID: "a,b", Channel: 1, Fields: FieldSSID | FieldBSSID, MinRSSI: -80, Sort: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []AccessPoint{{SSID: "a,b", RSSI: -60, BSSID: "ac:67:b2:00:00:01"}}
+	if !reflect.DeepEqual(aps, want) {
+		t.Errorf("Scan:\n%+v\n%+v", aps, want)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	m, d := newModule(t, esptest.Script{
+		"AT+CWLAPOPT=0,2047,0,1023": "\r\nOK\r\n",
+		"AT+CWLAP":                  "+CWLAP:(3,\"a\",-60,\"ac:67:b2:00:00:01\",1,-1,-1,4,4,7,1)\r\n",
+		"AT+CWMODE?":                "+CWMODE:1\r\n\r\nOK\r\n",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ssids := make(chan string, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- ScanFunc(ctx, d, nil, func(ap AccessPoint) {
+			ssids <- ap.SSID
+		})
+	}()
+	if ssid := <-ssids; ssid != "a" {
+		t.Errorf("fn called for %q", ssid)
+	}
+	// The scan doesn't end before the timeout.
+	if err := <-done; !errors.Is(err, espat.ErrTimeout) {
+		t.Fatalf("ScanFunc: %v; want %v", err, espat.ErrTimeout)
+	}
+	// The rest of the scan comes after the timeout.
+	m.Write("+CWLAP:(0,\"b\",-90,\"ac:67:b2:00:00:02\",6,-1,-1,0,0,3,0)\r\n\r\nOK\r\n")
+	// The next command gets its own response, not the rest of the scan.
+	md, err := GetMode(d)
+	if err != nil || md != ModeStation {
+		t.Errorf("GetMode: %v, %v", md, err)
+	}
+	select {
+	case ssid := <-ssids:
+		t.Errorf("fn called for %q after the timeout", ssid)
+	default:
+	}
+}
+
+func TestSoftAP(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CWLIF`: "+CWLIF:\"192.168.4.2\",\"18:fe:34:00:00:01\"\r\n\r\nOK\r\n",
+	})
+	stas, err := Stations(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stas) != 1 || stas[0].IP.String() != "192.168.4.2" ||
+		stas[0].MAC.String() != "18:fe:34:00:00:01" {
+		t.Errorf("Stations: %+v", stas)
+	}
+	for _, test := range []struct {
+		msg string
+		ev  string
+	}{
+		{`+STA_CONNECTED:"18:fe:34:00:00:01"`, "station 18:fe:34:00:00:01 connected"},
+		{`+DIST_STA_IP:"18:fe:34:00:00:01","192.168.4.2"`, "station 18:fe:34:00:00:01 got IP 192.168.4.2"},
+		{`+STA_DISCONNECTED:"18:fe:34:00:00:01"`, "station 18:fe:34:00:00:01 disconnected"},
+	} {
+		ev, ok := ParseStationEvent(espat.Async{Str: test.msg})
+		if !ok || ev.String() != test.ev {
+			t.Errorf("%s: %v %s", test.msg, ok, ev.String())
+		}
+	}
+	if _, ok := ParseStationEvent(espat.Async{Str: "WIFI GOT IP"}); ok {
+		t.Error("WIFI GOT IP: station event")
+	}
+}
+
+func TestIP(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CIPSTA?`: "+CIPSTA:ip:\"192.168.1.5\"\r\n+CIPSTA:gateway:\"192.168.1.1\"\r\n" +
+			"+CIPSTA:netmask:\"255.255.255.0\"\r\n+CIPSTA:ip6ll:\"fe80::a:b\"\r\n\r\nOK\r\n",
+		`AT+CIPAP="192.168.4.1","192.168.4.1","255.255.0.0"`: "\r\nOK\r\n",
+	})
+	cfg, err := GetIP(d, IfaceStation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IPConfig{
+		Addr:      netip.MustParsePrefix("192.168.1.5/24"),
+		Gateway:   netip.MustParseAddr("192.168.1.1"),
+		LinkLocal: netip.MustParseAddr("fe80::a:b"),
+	}
+	if *cfg != want {
+		t.Errorf("GetIP: %+v", *cfg)
+	}
+	err = SetIP(d, IfaceSoftAP, netip.MustParsePrefix("192.168.4.1/16"), netip.MustParseAddr("192.168.4.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMAC(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CIPSTAMAC?`:                   "+CIPSTAMAC:\"24:0a:c4:00:00:01\"\r\n\r\nOK\r\n",
+		`AT+CIPAPMAC="02:00:00:00:00:01"`: "\r\nOK\r\n",
+	})
+	mac, err := GetMAC(d, IfaceStation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mac.String() != "24:0a:c4:00:00:01" {
+		t.Errorf("GetMAC: %s", mac)
+	}
+	if err = SetMAC(d, IfaceSoftAP, net.HardwareAddr{2, 0, 0, 0, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	for _, mac := range []net.HardwareAddr{
+		{1, 0, 0, 0, 0, 1},
+		{0, 0, 0, 0, 0, 0},
+		{2, 0, 0, 0, 0, 0, 0, 1},
+	} {
+		if err = SetMAC(d, IfaceSoftAP, mac); !errors.Is(err, ErrInvalidMAC) {
+			t.Errorf("SetMAC(%s): %v", mac, err)
+		}
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CWRECONNCFG=1,60`: "\r\nOK\r\n",
+		`AT+CWSTATE?`:         "+CWSTATE:2,\"a\"\r\n\r\nOK\r\n",
+		`AT+CWJAP?`:           "+CWJAP:\"a\",\"ca:d7:19:d8:a6:44\",6,-55,0,0,0,0,0\r\n\r\nOK\r\n",
+	})
+	s, err := Supervise(d, SupervisorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if st := s.State(); st != GotIP {
+		t.Errorf("State: %v", st)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = s.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSupervisorReconnect(t *testing.T) {
+	const join = `AT+CWJAP="a","b",,,,,,5`
+	m, d := newModule(t, esptest.Script{
+		`AT+CWRECONNCFG=0,0`: "\r\nOK\r\n",
+		`AT+CWAUTOCONN=1`:    "\r\nOK\r\n",
+		`AT+CWSTATE?`:        "+CWSTATE:2,\"a\"\r\n\r\nOK\r\n",
+		`AT+CWJAP?`:          "+CWJAP:\"a\",\"ca:d7:19:d8:a6:44\",6,-55,0,0,0,0,0\r\n\r\nOK\r\n",
+		join:                 "+CWJAP:3\r\n\r\nERROR\r\n",
+	})
+	const minBackoff, maxBackoff = 50 * time.Millisecond, 100 * time.Millisecond
+	autoConn := true
+	linkLost := make(chan struct{}, 1)
+	s, err := Supervise(d, SupervisorConfig{
+		AP:           &Config{SSID: "a", Password: "b", JAPTimeout: 5 * time.Second},
+		ReconnRepeat: -1, // no firmware reconnection
+		AutoConnect:  &autoConn,
+		MinBackoff:   minBackoff,
+		MaxBackoff:   maxBackoff,
+		LinkLost:     func() { linkLost <- struct{}{} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	for _, want := range []string{"AT+CWRECONNCFG=0,0", "AT+CWAUTOCONN=1", "AT+CWSTATE?", "AT+CWJAP?"} {
+		if c := <-m.Cmds(); c != want {
+			t.Fatalf("command %q, want %q", c, want)
+		}
+	}
+	m.Write("WIFI DISCONNECT\r\n")
+	select {
+	case <-linkLost:
+	case <-time.After(time.Second):
+		t.Fatal("LinkLost not called")
+	}
+	// The first attempt is immediate, the next ones are made with the
+	// growing backoff limited by maxBackoff.
+	var last time.Time
+	for i, want := range []time.Duration{0, minBackoff, 2 * minBackoff, maxBackoff} {
+		select {
+		case c := <-m.Cmds():
+			if c != join {
+				t.Fatalf("command %q, want %q", c, join)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("attempt %d not made", i)
+		}
+		now := time.Now()
+		if i == 3 {
+			m.Set(join, "WIFI CONNECTED\r\nWIFI GOT IP\r\n\r\nOK\r\n")
+		}
+		if i != 0 {
+			if dt := now.Sub(last); dt < want*9/10 || dt >= 2*want {
+				t.Errorf("attempt %d after %v, want %v", i, dt, want)
+			}
+		}
+		last = now
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = s.WaitConnected(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKnown(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CWLAPOPT=0,14,0,1023`: "\r\nOK\r\n",
+		`AT+CWLAP`: "+CWLAP:(\"home\",-40,\"ac:67:b2:00:00:01\")\r\n" +
+			"+CWLAP:(\"work\",-70,\"ac:67:b2:00:00:02\")\r\n" +
+			"+CWLAP:(\"work\",-50,\"ac:67:b2:00:00:03\")\r\n\r\nOK\r\n",
+		`AT+CWJAP="work","b","ac:67:b2:00:00:03"`: "+CWJAP:2\r\n\r\nERROR\r\n",
+		`AT+CWJAP="home","a","ac:67:b2:00:00:01"`: "\r\nOK\r\n",
+	})
+	k, err := LoadKnown(FileStore(filepath.Join(t.TempDir(), "known.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []Network{
+		{"home", "a", 0},
+		{"work", "b", 1},
+		{"away", "c", 2},
+	} {
+		if err = k.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := k.Connect(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.SSID != "home" {
+		t.Errorf("Connect: %+v", *n)
+	}
+}
+
+func TestRoam(t *testing.T) {
+	d := newDevice(t, esptest.Script{
+		`AT+CWSTATE?`:             "+CWSTATE:2,\"a\"\r\n\r\nOK\r\n",
+		`AT+CWJAP?`:               "+CWJAP:\"a\",\"ca:d7:19:d8:a6:44\",6,-80,0,0,0,0,0\r\n\r\nOK\r\n",
+		`AT+CWLAPOPT=0,14,0,1023`: "\r\nOK\r\n",
+		`AT+CWLAP="a"`: "+CWLAP:(\"a\",-80,\"ca:d7:19:d8:a6:44\")\r\n" +
+			"+CWLAP:(\"a\",-75,\"ca:d7:19:d8:a6:45\")\r\n" +
+			"+CWLAP:(\"a\",-60,\"ca:d7:19:d8:a6:46\")\r\n\r\nOK\r\n",
+		`AT+CWJAP="a","b","ca:d7:19:d8:a6:46",,,,,15`: "WIFI CONNECTED\r\nWIFI GOT IP\r\n\r\nOK\r\n",
+	})
+	r := Roam(d, RoamConfig{Password: "b", Interval: 10 * time.Millisecond})
+	defer r.Stop()
+	select {
+	case ev := <-r.Events():
+		want := RoamEvent{"a", "ca:d7:19:d8:a6:44", -80, "ca:d7:19:d8:a6:46", -60, nil}
+		if ev != want {
+			t.Errorf("RoamEvent:\nhave %+v\nwant %+v", ev, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no roam event")
+	}
+}
+
+func TestWaitForIP(t *testing.T) {
+	cipsta := "+CIPSTA:ip:\"192.168.1.5\"\r\n+CIPSTA:gateway:\"192.168.1.1\"\r\n" +
+		"+CIPSTA:netmask:\"255.255.255.0\"\r\n\r\nOK\r\n"
+	want := IPConfig{
+		Addr:    netip.MustParsePrefix("192.168.1.5/24"),
+		Gateway: netip.MustParseAddr("192.168.1.1"),
+	}
+	for _, cwstate := range []string{
+		"+CWSTATE:2,\"a\"\r\n\r\nOK\r\n",
+		"+CWSTATE:3,\"a\"\r\n\r\nOK\r\nWIFI CONNECTED\r\nWIFI GOT IP\r\n",
+		// The data of an unknown connection doesn't end the waiting.
+		"+CWSTATE:3,\"a\"\r\n\r\nOK\r\n+IPD,3,2:ab\r\nWIFI GOT IP\r\n",
+	} {
+		d := newDevice(t, esptest.Script{
+			`AT+CWSTATE?`: cwstate,
+			`AT+CIPSTA?`:  cipsta,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		cfg, err := WaitForIP(ctx, d)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *cfg != want {
+			t.Errorf("WaitForIP: %+v", *cfg)
+		}
+	}
+}
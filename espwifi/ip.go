@@ -0,0 +1,253 @@
+package espwifi
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/cmds"
+)
+
+// Iface represents the network interface of the ESP-AT device.
+type Iface int8
+
+const (
+	IfaceStation  Iface = iota // AT+CIPSTA
+	IfaceSoftAP                // AT+CIPAP
+	IfaceEthernet              // AT+CIPETH
+)
+
+var ifaceCmds = [...]string{
+	IfaceStation:  "+CIPSTA",
+	IfaceSoftAP:   "+CIPAP",
+	IfaceEthernet: "+CIPETH",
+}
+
+var ifaceNames = [...]string{
+	IfaceStation:  "station",
+	IfaceSoftAP:   "softap",
+	IfaceEthernet: "ethernet",
+}
+
+func (i Iface) String() string {
+	if uint(i) < uint(len(ifaceNames)) {
+		return ifaceNames[i]
+	}
+	return "unknown"
+}
+
+// IPConfig contains the IP configuration of the interface. The fields not
+// reported by the device are invalid (zero) values. The IPv6 addresses are
+// reported only if IPv6 is enabled (AT+CIPV6=1).
+type IPConfig struct {
+	Addr      netip.Prefix // IPv4 address and netmask
+	Gateway   netip.Addr
+	LinkLocal netip.Addr // IPv6 link-local address
+	Global    netip.Addr // IPv6 global address
+}
+
+// GetIP returns the IP configuration of the interface.
+func GetIP(d *espat.Device, iface Iface) (*IPConfig, error) {
+	cmd := ifaceCmds[iface]
+	name := cmd + "?"
+	resp, err := d.Cmd(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(IPConfig)
+	var ip, mask netip.Addr
+	for _, v := range resp.Values(cmd) {
+		key, val, ok := strings.Cut(v, ":")
+		if !ok {
+			return nil, espat.ParseError(d, name)
+		}
+		args := espat.SplitArgs(val)
+		if len(args) == 0 {
+			return nil, espat.ParseError(d, name)
+		}
+		a, err := netip.ParseAddr(args[0])
+		if err != nil {
+			return nil, espat.ParseError(d, name)
+		}
+		switch key {
+		case "ip":
+			ip = a
+		case "gateway":
+			cfg.Gateway = a
+		case "netmask":
+			mask = a
+		case "ip6ll":
+			cfg.LinkLocal = a
+		case "ip6gl":
+			cfg.Global = a
+		}
+	}
+	if ip.IsValid() {
+		bits := 32
+		if mask.IsValid() {
+			bits = maskBits(mask)
+		}
+		cfg.Addr = netip.PrefixFrom(ip, bits)
+	}
+	return cfg, nil
+}
+
+// maskBits returns the prefix length of the netmask or -1 for the non-canonical
+// netmask.
+func maskBits(mask netip.Addr) int {
+	b := mask.As4()
+	m := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	n := 0
+	for m&(1<<31) != 0 {
+		m <<= 1
+		n++
+	}
+	if m != 0 {
+		return -1
+	}
+	return n
+}
+
+// netmask returns the IPv4 netmask of the prefix.
+func netmask(p netip.Prefix) netip.Addr {
+	m := ^uint32(0) << (32 - p.Bits())
+	return netip.AddrFrom4([4]byte{byte(m >> 24), byte(m >> 16), byte(m >> 8), byte(m)})
+}
+
+// SetIP sets the static IPv4 address, netmask and gateway of the interface.
+// The invalid gw means the gateway is not set (the netmask is also not set in
+// such case because ESP-AT requires both). Setting the static address of the
+// station or the Ethernet interface disables its DHCP client.
+func SetIP(d *espat.Device, iface Iface, addr netip.Prefix, gw netip.Addr) error {
+	name := ifaceCmds[iface] + "="
+	if !addr.Addr().Is4() || gw.IsValid() && !gw.Is4() {
+		return &espat.Error{Dev: d.Name(), Cmd: name, Err: espat.ErrArgType}
+	}
+	args := []any{addr.Addr().String()}
+	if gw.IsValid() {
+		args = append(args, gw.String(), netmask(addr).String())
+	}
+	_, err := d.Cmd(name, args...)
+	return err
+}
+
+// DHCPMask is a bitmask of the interfaces with DHCP enabled (AT+CWDHCP).
+type DHCPMask uint8
+
+const (
+	DHCPStation  DHCPMask = 1 << 0 // station DHCP client
+	DHCPSoftAP   DHCPMask = 1 << 1 // SoftAP DHCP server
+	DHCPEthernet DHCPMask = 1 << 2 // Ethernet DHCP client
+)
+
+// DHCP returns the interfaces with DHCP enabled.
+func DHCP(d *espat.Device) (DHCPMask, error) {
+	r, err := cmds.CWDHCP{}.Query(d)
+	if err != nil {
+		return 0, err
+	}
+	if r == nil {
+		return 0, espat.ParseError(d, "+CWDHCP?")
+	}
+	return DHCPMask(r.State), nil
+}
+
+// SetDHCP enables or disables DHCP on the interfaces selected by mask.
+func SetDHCP(d *espat.Device, mask DHCPMask, enable bool) error {
+	op := 0
+	if enable {
+		op = 1
+	}
+	_, err := d.Cmd("+CWDHCP=", op, int(mask))
+	return err
+}
+
+// DHCPServer contains the configuration of the SoftAP DHCP server
+// (AT+CWDHCPS).
+type DHCPServer struct {
+	Lease time.Duration // lease time (1 to 2880 minutes)
+	Start netip.Addr    // the first address of the pool
+	End   netip.Addr    // the last address of the pool
+}
+
+// GetDHCPServer returns the configuration of the SoftAP DHCP server.
+func GetDHCPServer(d *espat.Device) (*DHCPServer, error) {
+	const name = "+CWDHCPS?"
+	args, err := d.Query(name, 3)
+	if err != nil {
+		return nil, err
+	}
+	srv := &DHCPServer{Lease: time.Duration(atoi(args[0])) * time.Minute}
+	if srv.Start, err = netip.ParseAddr(args[1]); err != nil {
+		return nil, espat.ParseError(d, name)
+	}
+	if srv.End, err = netip.ParseAddr(args[2]); err != nil {
+		return nil, espat.ParseError(d, name)
+	}
+	return srv, nil
+}
+
+// SetDHCPServer configures the SoftAP DHCP server. The nil srv restores the
+// default configuration.
+func SetDHCPServer(d *espat.Device, srv *DHCPServer) error {
+	if srv == nil {
+		_, err := d.Cmd("+CWDHCPS=0")
+		return err
+	}
+	_, err := d.Cmd("+CWDHCPS=1,", int(srv.Lease/time.Minute),
+		srv.Start.String(), srv.End.String())
+	return err
+}
+
+// WaitForIP waits until the station obtains the IPv4 address and returns its
+// IP configuration. It returns immediately if the station already has the
+// address. The ctx limits the waiting time. The errors caused by the
+// unrelated messages (espat.ErrParse, espat.ErrUnkConn) are ignored. The
+// other errors reported by the device receiver (e.g. the I/O errors) end the
+// waiting.
+func WaitForIP(ctx context.Context, d *espat.Device) (*IPConfig, error) {
+	events := make(chan espat.Async, 4)
+	d.Notify(events)
+	defer d.StopNotify(events)
+	gotIP := false
+	if !d.Legacy() {
+		r, err := cmds.CWSTATE{}.Query(d)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return nil, espat.ParseError(d, "+CWSTATE?")
+		}
+		gotIP = StationState(r.State) == GotIP
+	}
+	if gotIP || d.Legacy() {
+		// The legacy firmware doesn't report the GotIP state so check the
+		// address itself.
+		cfg, err := GetIP(d, IfaceStation)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Addr.IsValid() && !cfg.Addr.Addr().IsUnspecified() {
+			return cfg, nil
+		}
+	}
+	for {
+		select {
+		case msg := <-events:
+			if msg.Err != nil {
+				if errors.Is(msg.Err, espat.ErrParse) || errors.Is(msg.Err, espat.ErrUnkConn) {
+					continue
+				}
+				return nil, msg.Err
+			}
+			if msg.Str == "WIFI GOT IP" {
+				return GetIP(d, IfaceStation)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
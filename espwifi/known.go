@@ -0,0 +1,196 @@
+package espwifi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/espsys"
+)
+
+// ErrNoKnownNetwork is returned by Known.Connect if none of the known
+// networks was found by the scan.
+var ErrNoKnownNetwork = errors.New("no known network")
+
+// Network describes a known network.
+type Network struct {
+	SSID     string
+	Password string
+	Priority int // networks with the higher priority are preferred
+}
+
+// Store is a persistent storage of the known networks list.
+type Store interface {
+	Load() ([]Network, error)
+	Save(nets []Network) error
+}
+
+// FileStore stores the known networks in the host file as JSON. The missing
+// file means the empty list.
+type FileStore string
+
+// Load implements the Store interface.
+func (fs FileStore) Load() ([]Network, error) {
+	data, err := os.ReadFile(string(fs))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var nets []Network
+	err = json.Unmarshal(data, &nets)
+	return nets, err
+}
+
+// Save implements the Store interface.
+func (fs FileStore) Save(nets []Network) error {
+	data, err := json.MarshalIndent(nets, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(string(fs), data, 0o600)
+}
+
+// MfgStore stores the known networks as JSON in the binary key of the ESP
+// manufacturing NVS partition (see espsys.MfgWriteBinary). The namespace must
+// exist in the partition. The missing key means the empty list.
+type MfgStore struct {
+	Dev       *espat.Device
+	Namespace string
+	Key       string
+}
+
+// Load implements the Store interface.
+func (ms *MfgStore) Load() ([]Network, error) {
+	data, err := espsys.MfgReadBinary(ms.Dev, ms.Namespace, ms.Key)
+	if err != nil {
+		var e *espat.ErrorESP
+		if errors.As(err, &e) {
+			return nil, nil // no such key
+		}
+		return nil, err
+	}
+	var nets []Network
+	err = json.Unmarshal(data, &nets)
+	return nets, err
+}
+
+// Save implements the Store interface.
+func (ms *MfgStore) Save(nets []Network) error {
+	data, err := json.Marshal(nets)
+	if err != nil {
+		return err
+	}
+	return espsys.MfgWriteBinary(ms.Dev, ms.Namespace, ms.Key, bytes.NewReader(data), len(data))
+}
+
+// Known is a list of known networks.
+type Known struct {
+	mx    sync.Mutex
+	store Store
+	nets  []Network
+}
+
+// LoadKnown returns the known networks list loaded from the store.
+func LoadKnown(store Store) (*Known, error) {
+	nets, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Known{store: store, nets: nets}, nil
+}
+
+// List returns a copy of the list.
+func (k *Known) List() []Network {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	return append([]Network(nil), k.nets...)
+}
+
+// Add adds the network to the list or replaces the one with the same SSID.
+// The list is saved in the store.
+func (k *Known) Add(n Network) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	for i := range k.nets {
+		if k.nets[i].SSID == n.SSID {
+			k.nets[i] = n
+			return k.store.Save(k.nets)
+		}
+	}
+	k.nets = append(k.nets, n)
+	return k.store.Save(k.nets)
+}
+
+// Remove removes the network with the given SSID from the list. The list is
+// saved in the store.
+func (k *Known) Remove(ssid string) error {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+	for i := range k.nets {
+		if k.nets[i].SSID == ssid {
+			k.nets = append(k.nets[:i], k.nets[i+1:]...)
+			return k.store.Save(k.nets)
+		}
+	}
+	return nil
+}
+
+type candidate struct {
+	n  Network
+	ap AccessPoint
+}
+
+// Connect scans for the APs and joins the best available known network. The
+// networks are tried in the order of priority and then RSSI (the strongest AP
+// of the network is used). If the join fails the next network is tried. The
+// ctx deadline limits the whole operation. Connect returns the joined
+// network, ErrNoKnownNetwork if none of the known networks is available or
+// the last Join error.
+func (k *Known) Connect(ctx context.Context, d *espat.Device) (*Network, error) {
+	aps, err := Scan(ctx, d, &ScanOptions{Fields: FieldSSID | FieldRSSI | FieldBSSID})
+	if err != nil {
+		return nil, err
+	}
+	var cands []candidate
+	for _, n := range k.List() {
+		best := -1
+		for i, ap := range aps {
+			if ap.SSID == n.SSID && (best < 0 || ap.RSSI > aps[best].RSSI) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			cands = append(cands, candidate{n, aps[best]})
+		}
+	}
+	if len(cands) == 0 {
+		return nil, &espat.Error{Dev: d.Name(), Cmd: "+CWLAP", Err: ErrNoKnownNetwork}
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		if cands[i].n.Priority != cands[j].n.Priority {
+			return cands[i].n.Priority > cands[j].n.Priority
+		}
+		return cands[i].ap.RSSI > cands[j].ap.RSSI
+	})
+	for _, c := range cands {
+		err = Join(ctx, d, &Config{
+			SSID:     c.n.SSID,
+			Password: c.n.Password,
+			BSSID:    c.ap.BSSID,
+		})
+		if err == nil {
+			return &c.n, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,61 @@
+package espwifi
+
+import (
+	"errors"
+	"net"
+
+	"github.com/embeddedgo/espat"
+)
+
+var macCmds = [...]string{
+	IfaceStation:  "+CIPSTAMAC",
+	IfaceSoftAP:   "+CIPAPMAC",
+	IfaceEthernet: "+CIPETHMAC",
+}
+
+// GetMAC returns the MAC address of the interface.
+func GetMAC(d *espat.Device, iface Iface) (net.HardwareAddr, error) {
+	name := macCmds[iface] + "?"
+	args, err := d.Query(name, 1)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := net.ParseMAC(args[0])
+	if err != nil {
+		return nil, espat.ParseError(d, name)
+	}
+	return mac, nil
+}
+
+// ErrInvalidMAC is returned by SetMAC in the espat.Error Err field if the MAC
+// address can't be assigned to the interface.
+var ErrInvalidMAC = errors.New("invalid MAC address")
+
+// SetMAC sets the MAC address of the interface. The address must be a 6-byte
+// unicast address (bit 0 of the first byte cleared) other than all zeros.
+// ESP-AT additionally requires different station and SoftAP addresses.
+//
+// The address is stored in the flash and survives the reset only if the
+// configuration storing is enabled (see espat.Device.SysStore and
+// espat.WithSysStore). Otherwise the factory address is restored after the
+// reset.
+func SetMAC(d *espat.Device, iface Iface, mac net.HardwareAddr) error {
+	name := macCmds[iface] + "="
+	if !validMAC(mac) {
+		return &espat.Error{Dev: d.Name(), Cmd: name, Err: ErrInvalidMAC}
+	}
+	_, err := d.Cmd(name, mac.String())
+	return err
+}
+
+func validMAC(mac net.HardwareAddr) bool {
+	if len(mac) != 6 || mac[0]&1 != 0 {
+		return false
+	}
+	for _, b := range mac {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
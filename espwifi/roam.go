@@ -0,0 +1,154 @@
+package espwifi
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/embeddedgo/espat"
+)
+
+// RoamConfig contains the Roamer parameters. The zero values are replaced by
+// defaults.
+type RoamConfig struct {
+	// Password of the roamed network (the SSID is taken from the current
+	// connection).
+	Password string
+
+	// Interval is the RSSI sampling interval (10 s).
+	Interval time.Duration
+
+	// Threshold is the RSSI (dBm) below which the roamer scans for a better
+	// AP (-70 dBm).
+	Threshold int
+
+	// Hysteresis is the minimum RSSI gain (dB) of the other AP required to
+	// roam to it (8 dB).
+	Hysteresis int
+
+	// ScanTime limits the duration of the targeted scan (5 s).
+	ScanTime time.Duration
+}
+
+// RoamEvent describes the roaming attempt.
+type RoamEvent struct {
+	SSID     string
+	From     string // BSSID of the previous AP
+	FromRSSI int
+	To       string // BSSID of the new AP
+	ToRSSI   int
+	Err      error // nil if the station joined the new AP
+}
+
+// Roamer moves the station connection between the APs of the same SSID. It
+// periodically samples the RSSI of the current AP (AT+CWJAP?) and if it falls
+// below the threshold it scans for the APs with the same SSID and joins the
+// strongest one (AT+CWJAP with BSSID) if it's better by the hysteresis. Use
+// Roam to create a roamer.
+type Roamer struct {
+	d      *espat.Device
+	cfg    RoamConfig
+	events chan RoamEvent
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// Roam starts a goroutine that roams the station connection of d.
+func Roam(d *espat.Device, cfg RoamConfig) *Roamer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.Threshold == 0 {
+		cfg.Threshold = -70
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = 8
+	}
+	if cfg.ScanTime <= 0 {
+		cfg.ScanTime = 5 * time.Second
+	}
+	r := &Roamer{
+		d:      d,
+		cfg:    cfg,
+		events: make(chan RoamEvent, 4),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Events returns the channel of roam events. The events are dropped if the
+// channel is full.
+func (r *Roamer) Events() <-chan RoamEvent {
+	return r.events
+}
+
+// Stop stops the roamer goroutine and waits for its termination.
+func (r *Roamer) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *Roamer) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.check()
+		}
+	}
+}
+
+func (r *Roamer) check() {
+	st, err := State(r.d)
+	if err != nil || st.BSSID == "" || st.RSSI >= r.cfg.Threshold {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.ScanTime)
+	aps, err := Scan(ctx, r.d, &ScanOptions{
+		SSID:   st.SSID,
+		Fields: FieldSSID | FieldRSSI | FieldBSSID,
+	})
+	cancel()
+	if err != nil {
+		return
+	}
+	var best *AccessPoint
+	for i := range aps {
+		ap := &aps[i]
+		if ap.SSID != st.SSID || strings.EqualFold(ap.BSSID, st.BSSID) {
+			continue
+		}
+		if best == nil || ap.RSSI > best.RSSI {
+			best = ap
+		}
+	}
+	if best == nil || best.RSSI < st.RSSI+r.cfg.Hysteresis {
+		return
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), joinTimeout)
+	err = Join(ctx, r.d, &Config{
+		SSID:       st.SSID,
+		Password:   r.cfg.Password,
+		BSSID:      best.BSSID,
+		JAPTimeout: 15 * time.Second,
+	})
+	cancel()
+	ev := RoamEvent{
+		SSID:     st.SSID,
+		From:     st.BSSID,
+		FromRSSI: st.RSSI,
+		To:       best.BSSID,
+		ToRSSI:   best.RSSI,
+		Err:      err,
+	}
+	select {
+	case r.events <- ev:
+	default:
+	}
+}
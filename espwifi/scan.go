@@ -0,0 +1,270 @@
+package espwifi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/embeddedgo/espat"
+)
+
+// Encryption represents the authentication mode of the AP.
+type Encryption int8
+
+const (
+	Open Encryption = iota
+	WEP
+	WPA_PSK
+	WPA2_PSK
+	WPA_WPA2_PSK
+	WPA2_Enterprise
+	WPA3_PSK
+	WPA2_WPA3_PSK
+	WAPI_PSK
+	OWE
+)
+
+var encryptions = [...]string{
+	Open:            "open",
+	WEP:             "WEP",
+	WPA_PSK:         "WPA_PSK",
+	WPA2_PSK:        "WPA2_PSK",
+	WPA_WPA2_PSK:    "WPA_WPA2_PSK",
+	WPA2_Enterprise: "WPA2_ENTERPRISE",
+	WPA3_PSK:        "WPA3_PSK",
+	WPA2_WPA3_PSK:   "WPA2_WPA3_PSK",
+	WAPI_PSK:        "WAPI_PSK",
+	OWE:             "OWE",
+}
+
+func (e Encryption) String() string {
+	if uint(e) < uint(len(encryptions)) {
+		return encryptions[e]
+	}
+	return "unknown"
+}
+
+// Cipher represents the pairwise or group cipher used by the AP.
+type Cipher int8
+
+const (
+	CipherNone Cipher = iota
+	CipherWEP40
+	CipherWEP104
+	CipherTKIP
+	CipherCCMP
+	CipherTKIP_CCMP
+	CipherAES_CMAC128
+	CipherUnknown
+)
+
+// PHY represents the bgn flags of the AP.
+type PHY uint8
+
+const (
+	PHY11b PHY = 1 << 0
+	PHY11g PHY = 1 << 1
+	PHY11n PHY = 1 << 2
+)
+
+// AccessPoint describes the AP found by Scan. The fields not selected by
+// ScanOptions.Fields are left zero.
+type AccessPoint struct {
+	ECN        Encryption
+	SSID       string
+	RSSI       int    // signal strength in dBm
+	BSSID      string // MAC address of the AP
+	Channel    int
+	FreqOffset int // frequency offset (reserved)
+	FreqCal    int // frequency calibration value (reserved)
+	Pairwise   Cipher
+	Group      Cipher
+	PHY        PHY
+	WPS        bool
+}
+
+// Field is a bitmask of the AccessPoint fields reported by AT+CWLAP (see
+// AT+CWLAPOPT print mask).
+type Field uint16
+
+const (
+	FieldECN Field = 1 << iota
+	FieldSSID
+	FieldRSSI
+	FieldBSSID
+	FieldChannel
+	FieldFreqOffset
+	FieldFreqCal
+	FieldPairwise
+	FieldGroup
+	FieldPHY
+	FieldWPS
+
+	FieldAll Field = 1<<iota - 1
+)
+
+// ScanOptions contains the Scan parameters. The zero value means a full scan
+// of all channels that reports all fields.
+type ScanOptions struct {
+	// Targeted scan. The empty SSID, BSSID and zero Channel match all APs.
+	SSID    string
+	BSSID   string
+	Channel int
+
+	Fields  Field // reported fields (RSSI is always reported), 0 means FieldAll
+	MinRSSI int   // report only the APs with RSSI >= MinRSSI (if not 0)
+
+	// AuthModes is the bitmask of the reported authentication modes
+	// (1<<Encryption), 0 means all.
+	AuthModes uint16
+
+	// Sort the results by RSSI (the firmware may ignore it).
+	Sort bool
+
+	// Passive scan and the per channel scan time. The zero times select
+	// the firmware defaults.
+	Passive bool
+	MinTime time.Duration
+	MaxTime time.Duration
+}
+
+// Scan scans for the APs (AT+CWLAP) and returns the found ones. The ctx
+// deadline limits the scan time. Opts can be nil.
+func Scan(ctx context.Context, d *espat.Device, opts *ScanOptions) ([]AccessPoint, error) {
+	var aps []AccessPoint
+	err := ScanFunc(ctx, d, opts, func(ap AccessPoint) {
+		aps = append(aps, ap)
+	})
+	return aps, err
+}
+
+// ScanFunc works like Scan but calls fn for every AP as soon as the
+// corresponding +CWLAP line is received. Fn is called by the receiver
+// goroutine so it must not execute any device commands and should return
+// quickly. Fn isn't called after ScanFunc returns.
+//
+// The device is locked during the scan so only one scan at a time is
+// performed on the device. ScanFunc configures the scan using AT+CWLAPOPT and
+// leaves it changed (ESP-AT doesn't allow to query it) so the code that
+// executes AT+CWLAP itself should also set AT+CWLAPOPT.
+func ScanFunc(ctx context.Context, d *espat.Device, opts *ScanOptions, fn func(ap AccessPoint)) error {
+	if opts == nil {
+		opts = new(ScanOptions)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fields := opts.Fields
+	if fields == 0 {
+		fields = FieldAll
+	}
+	fields |= FieldRSSI // required by the MinRSSI filter
+	sort := 0
+	if opts.Sort {
+		sort = 1
+	}
+	lapopt := []any{sort, int(fields)}
+	if !d.Legacy() {
+		authModes := int(opts.AuthModes)
+		if authModes == 0 {
+			authModes = 1<<(OWE+1) - 1
+		}
+		lapopt = append(lapopt, opts.MinRSSI, authModes)
+	}
+	var args []any
+	if opts.SSID != "" || opts.BSSID != "" || opts.Channel != 0 ||
+		opts.Passive || opts.MinTime != 0 || opts.MaxTime != 0 {
+		args = []any{espat.Opt(opts.SSID), espat.Opt(opts.BSSID), espat.Opt(opts.Channel)}
+		if opts.Passive || opts.MinTime != 0 || opts.MaxTime != 0 {
+			scanType := 0
+			if opts.Passive {
+				scanType = 1
+			}
+			args = append(args, scanType,
+				espat.Opt(int(opts.MinTime/time.Millisecond)),
+				espat.Opt(int(opts.MaxTime/time.Millisecond)))
+		}
+		for args[len(args)-1] == nil {
+			args = args[:len(args)-1] // skip the trailing default parameters
+		}
+	}
+	d.Lock()
+	defer d.Unlock()
+	if _, err := d.UnsafeCmd("+CWLAPOPT=", lapopt...); err != nil {
+		return err
+	}
+	var (
+		mx   sync.Mutex
+		done bool
+	)
+	d.Handle("+CWLAP:", false, func(line string, _ []byte) {
+		mx.Lock()
+		defer mx.Unlock()
+		if done {
+			return // the late line of the timed out scan
+		}
+		ap, ok := parseAP(line[7:], fields)
+		if ok && (opts.MinRSSI == 0 || ap.RSSI >= opts.MinRSSI) {
+			fn(ap)
+		}
+	})
+	name := "+CWLAP"
+	if args != nil {
+		name = "+CWLAP="
+	}
+	_, err := d.UnsafeCmdTimeout(cmdTimeout(ctx), name, args...)
+	mx.Lock()
+	done = true
+	mx.Unlock()
+	if !errors.Is(err, espat.ErrTimeout) {
+		d.Handle("+CWLAP:", false, nil)
+	}
+	// Otherwise the scan is still running. Leave the handler to discard its
+	// remaining lines. The next scan replaces it.
+	return err
+}
+
+// parseAP parses the +CWLAP line value that contains the fields selected by
+// the mask: (<ecn>,<"ssid">,<rssi>,<"mac">,<channel>,...).
+func parseAP(s string, fields Field) (ap AccessPoint, ok bool) {
+	s = strings.TrimPrefix(s, "(")
+	s = strings.TrimSuffix(s, ")")
+	args := espat.SplitArgs(s)
+	for f := Field(1); f < FieldAll; f <<= 1 {
+		if fields&f == 0 {
+			continue
+		}
+		if len(args) == 0 {
+			return ap, false
+		}
+		a := args[0]
+		args = args[1:]
+		switch f {
+		case FieldECN:
+			ap.ECN = Encryption(atoi(a))
+		case FieldSSID:
+			ap.SSID = a
+		case FieldRSSI:
+			ap.RSSI = atoi(a)
+		case FieldBSSID:
+			ap.BSSID = a
+		case FieldChannel:
+			ap.Channel = atoi(a)
+		case FieldFreqOffset:
+			ap.FreqOffset = atoi(a)
+		case FieldFreqCal:
+			ap.FreqCal = atoi(a)
+		case FieldPairwise:
+			ap.Pairwise = Cipher(atoi(a))
+		case FieldGroup:
+			ap.Group = Cipher(atoi(a))
+		case FieldPHY:
+			ap.PHY = PHY(atoi(a))
+		case FieldWPS:
+			ap.WPS = a == "1"
+		}
+	}
+	return ap, true
+}
@@ -0,0 +1,169 @@
+package espwifi
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/cmds"
+)
+
+// APConfig contains the SoftAP configuration (AT+CWSAP).
+type APConfig struct {
+	SSID     string
+	Password string // 8 to 64 bytes, ignored for the Open encryption
+	Channel  int
+	ECN      Encryption // Open, WPA_PSK, WPA2_PSK or WPA_WPA2_PSK
+	MaxConn  int        // maximum number of stations (1 to 10), 0 means default
+	Hidden   bool       // don't broadcast SSID
+}
+
+// ConfigureAP configures the SoftAP. The SoftAP must be enabled (see
+// SetMode).
+func ConfigureAP(d *espat.Device, cfg *APConfig) error {
+	_, err := cmds.CWSAP{
+		SSID:     cfg.SSID,
+		Password: cfg.Password,
+		Channel:  cfg.Channel,
+		ECN:      int(cfg.ECN),
+		MaxConn:  cfg.MaxConn,
+		Hidden:   cfg.Hidden,
+	}.Set(d)
+	return err
+}
+
+// GetAP returns the current SoftAP configuration.
+func GetAP(d *espat.Device) (*APConfig, error) {
+	r, err := cmds.CWSAP{}.Query(d)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, espat.ParseError(d, "+CWSAP?")
+	}
+	return &APConfig{
+		SSID:     r.SSID,
+		Password: r.Password,
+		Channel:  r.Channel,
+		ECN:      Encryption(r.ECN),
+		MaxConn:  r.MaxConn,
+		Hidden:   r.Hidden,
+	}, nil
+}
+
+// Station describes the station connected to the SoftAP.
+type Station struct {
+	MAC net.HardwareAddr
+	IP  netip.Addr
+}
+
+// Stations returns the stations connected to the SoftAP (AT+CWLIF).
+func Stations(d *espat.Device) ([]Station, error) {
+	const name = "+CWLIF"
+	resp, err := d.Cmd(name)
+	if err != nil {
+		return nil, err
+	}
+	vals := resp.Values("+CWLIF")
+	if vals == nil {
+		vals = resp.Values("") // legacy firmware: <ip>,<mac>
+	}
+	var stas []Station
+	for _, v := range vals {
+		args := espat.SplitArgs(v)
+		if len(args) < 2 {
+			return nil, espat.ParseError(d, name)
+		}
+		var sta Station
+		if sta.IP, err = netip.ParseAddr(args[0]); err != nil {
+			return nil, espat.ParseError(d, name)
+		}
+		if sta.MAC, err = net.ParseMAC(args[1]); err != nil {
+			return nil, espat.ParseError(d, name)
+		}
+		stas = append(stas, sta)
+	}
+	return stas, nil
+}
+
+// Disconnect disconnects the station with the given MAC address from the
+// SoftAP (AT+CWQIF). The nil mac disconnects all stations.
+func Disconnect(d *espat.Device, mac net.HardwareAddr) error {
+	if mac == nil {
+		_, err := d.Cmd("+CWQIF")
+		return err
+	}
+	_, err := d.Cmd("+CWQIF=", mac.String())
+	return err
+}
+
+// StationEventType represents the type of the SoftAP station event.
+type StationEventType int8
+
+const (
+	StationConnected    StationEventType = iota // +STA_CONNECTED
+	StationGotIP                                // +DIST_STA_IP
+	StationDisconnected                         // +STA_DISCONNECTED
+)
+
+var stationEvents = [...]string{
+	StationConnected:    "connected",
+	StationGotIP:        "got IP",
+	StationDisconnected: "disconnected",
+}
+
+func (t StationEventType) String() string {
+	if uint(t) < uint(len(stationEvents)) {
+		return stationEvents[t]
+	}
+	return "unknown"
+}
+
+// StationEvent represents the SoftAP station event received from the Async
+// channel. The IP field is valid only for the StationGotIP event.
+type StationEvent struct {
+	Type StationEventType
+	Station
+}
+
+func (e *StationEvent) String() string {
+	s := "station " + e.MAC.String() + " " + e.Type.String()
+	if e.IP.IsValid() {
+		s += " " + e.IP.String()
+	}
+	return s
+}
+
+// ParseStationEvent parses the SoftAP station event. It reports whether the
+// msg was a valid station event.
+func ParseStationEvent(msg espat.Async) (ev StationEvent, ok bool) {
+	if msg.Err != nil {
+		return
+	}
+	s := msg.Str
+	switch {
+	case len(s) > 15 && s[:15] == "+STA_CONNECTED:":
+		ev.Type, s = StationConnected, s[15:]
+	case len(s) > 18 && s[:18] == "+STA_DISCONNECTED:":
+		ev.Type, s = StationDisconnected, s[18:]
+	case len(s) > 13 && s[:13] == "+DIST_STA_IP:":
+		ev.Type, s = StationGotIP, s[13:]
+	default:
+		return
+	}
+	args := espat.SplitArgs(s)
+	mac, err := net.ParseMAC(args[0])
+	if err != nil {
+		return
+	}
+	ev.MAC = mac
+	if ev.Type == StationGotIP {
+		if len(args) < 2 {
+			return
+		}
+		if ev.IP, err = netip.ParseAddr(args[1]); err != nil {
+			return
+		}
+	}
+	return ev, true
+}
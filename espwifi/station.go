@@ -0,0 +1,217 @@
+package espwifi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/cmds"
+)
+
+// Errors returned by Join in the espat.Error Err field. They correspond to
+// the +CWJAP:<code> error codes.
+var (
+	ErrJoinTimeout   = errors.New("connection timeout")
+	ErrWrongPassword = errors.New("wrong password")
+	ErrNoAP          = errors.New("cannot find the target AP")
+	ErrConnFailed    = errors.New("connection failed")
+)
+
+var joinErrors = [...]error{
+	1: ErrJoinTimeout,
+	2: ErrWrongPassword,
+	3: ErrNoAP,
+	4: ErrConnFailed,
+}
+
+// Config contains the parameters of the AP connection (AT+CWJAP). Only SSID
+// is required. The zero values of the other fields select the firmware
+// defaults.
+type Config struct {
+	SSID     string
+	Password string
+	BSSID    string // MAC address of the AP (e.g. "ca:d7:19:d8:a6:44")
+
+	// PCIOnly disables the connection to the WEP and open APs.
+	PCIOnly bool
+
+	// ReconnInterval is the interval between the reconnection attempts
+	// (1 to 7200 s). Zero selects the firmware default (1 s).
+	ReconnInterval time.Duration
+
+	// NoReconnect disables the reconnection after the connection is lost
+	// (ReconnInterval is ignored).
+	NoReconnect bool
+
+	// ListenInterval is the interval of listening to the AP beacons in the
+	// beacon intervals (1 to 100, default 3).
+	ListenInterval int
+
+	// ScanMode selects the scan mode: 0 - fast scan (connect to the first
+	// found AP), 1 - all channel scan (connect to the strongest AP).
+	ScanMode int
+
+	// JAPTimeout is the maximum time to wait for the connection (3 to 600
+	// s, default 15 s). If it is zero Join derives it from the ctx deadline.
+	JAPTimeout time.Duration
+
+	// PMF configures the Protected Management Frames: 0 - disabled,
+	// 1 - capable, 3 - required.
+	PMF int
+}
+
+// Join connects the station to the AP described by cfg and waits for the
+// result. The ctx deadline limits the waiting time. The canceling of ctx
+// without deadline doesn't interrupt the connecting. If cfg.JAPTimeout is
+// zero the ctx deadline is passed to ESP-AT as the connection timeout so it
+// is extended to 3 s if shorter. Otherwise the ctx deadline shorter than
+// cfg.JAPTimeout may end Join with the ErrTimeout error before ESP-AT reports
+// the result (the connecting isn't interrupted).
+//
+// The failures reported by ESP-AT as +CWJAP:<code> are returned as the
+// espat.Error with Err set to ErrJoinTimeout, ErrWrongPassword, ErrNoAP or
+// ErrConnFailed.
+func Join(ctx context.Context, d *espat.Device, cfg *Config) error {
+	const name = "+CWJAP="
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	timeout := cmdTimeout(ctx)
+	jt := cfg.JAPTimeout
+	if jt == 0 {
+		jt = timeout
+	}
+	c := cmds.CWJAP{
+		SSID:           cfg.SSID,
+		Password:       cfg.Password,
+		BSSID:          cfg.BSSID,
+		PCIOnly:        cfg.PCIOnly,
+		ReconnInterval: int(cfg.ReconnInterval / time.Second),
+		ListenInterval: cfg.ListenInterval,
+		ScanMode:       cfg.ScanMode,
+		PMF:            cfg.PMF,
+	}
+	if jt != 0 {
+		s := int(jt / time.Second)
+		if s < 3 {
+			s = 3
+		} else if s > 600 {
+			s = 600
+		}
+		c.JAPTimeout = s
+		if cfg.JAPTimeout == 0 {
+			// ESP-AT doesn't report its timeout before s seconds.
+			timeout = time.Duration(s) * time.Second
+		}
+	}
+	if timeout != 0 {
+		timeout += time.Second // let ESP-AT report its own timeout first
+	}
+	args := c.Args()
+	if cfg.NoReconnect {
+		// The zero ReconnInterval (the fifth argument) is omitted by Args.
+		for len(args) < 5 {
+			args = append(args, nil)
+		}
+		args[4] = 0
+	}
+	resp, err := d.CmdTimeout(timeout, name, args...)
+	if err == nil {
+		return nil
+	}
+	var e *espat.Error
+	if errors.As(err, &e) {
+		if _, ok := e.Err.(*espat.ErrorESP); ok {
+			if v, ok := resp.Value("+CWJAP"); ok {
+				if c, _ := strconv.Atoi(v); uint(c) < uint(len(joinErrors)) && joinErrors[c] != nil {
+					e.Err = joinErrors[c]
+				}
+			}
+		}
+	}
+	return err
+}
+
+// Quit disconnects the station from the AP (AT+CWQAP).
+func Quit(d *espat.Device) error {
+	_, err := cmds.CWQAP{}.Exec(d)
+	return err
+}
+
+// StationState represents the state of the station (AT+CWSTATE?).
+type StationState int8
+
+const (
+	NotStarted   StationState = 0 // no connection attempt since boot
+	Connected    StationState = 1 // connected to the AP, no IPv4 address
+	GotIP        StationState = 2 // connected to the AP, IPv4 address obtained
+	Connecting   StationState = 3 // connecting or reconnecting
+	Disconnected StationState = 4
+)
+
+var stationStates = [...]string{
+	NotStarted:   "not started",
+	Connected:    "connected",
+	GotIP:        "got IP",
+	Connecting:   "connecting",
+	Disconnected: "disconnected",
+}
+
+func (s StationState) String() string {
+	if uint(s) < uint(len(stationStates)) {
+		return stationStates[s]
+	}
+	return "unknown"
+}
+
+// Status describes the current station connection.
+type Status struct {
+	State   StationState
+	SSID    string
+	BSSID   string // MAC address of the AP
+	Channel int
+	RSSI    int // signal strength in dBm
+}
+
+// State returns the current state of the station. The AP related fields are
+// set only if the station is connected to the AP. The legacy firmware
+// doesn't support AT+CWSTATE so the state is derived from AT+CWJAP? and can
+// be only Connected or Disconnected.
+func State(d *espat.Device) (*Status, error) {
+	st := new(Status)
+	if !d.Legacy() {
+		r, err := cmds.CWSTATE{}.Query(d)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return nil, espat.ParseError(d, "+CWSTATE?")
+		}
+		st.State = StationState(r.State)
+		if st.State != Connected && st.State != GotIP {
+			st.SSID = r.SSID
+			return st, nil
+		}
+	}
+	r, err := cmds.CWJAP{}.Query(d)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		// "No AP"
+		if d.Legacy() {
+			st.State = Disconnected
+		}
+		return st, nil
+	}
+	if d.Legacy() {
+		st.State = Connected
+	}
+	st.SSID = r.SSID
+	st.BSSID = r.BSSID
+	st.Channel = r.Channel
+	st.RSSI = r.RSSI
+	return st, nil
+}
@@ -0,0 +1,239 @@
+package espwifi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/cmds"
+)
+
+// SupervisorConfig contains the Supervisor parameters. The zero values are
+// replaced by defaults (nil AutoConnect leaves the firmware setting
+// unchanged).
+type SupervisorConfig struct {
+	// AP to join if the firmware gives up reconnecting. Nil means the
+	// supervisor only observes the connection state.
+	AP *Config
+
+	// Firmware reconnection parameters (AT+CWRECONNCFG): the interval
+	// between attempts (1 s) and the number of attempts (60, negative value
+	// disables the firmware reconnection).
+	ReconnInterval time.Duration
+	ReconnRepeat   int
+
+	// AutoConnect enables or disables the connection to the saved AP after
+	// the reset (AT+CWAUTOCONN).
+	AutoConnect *bool
+
+	// MinBackoff and MaxBackoff limit the exponentially growing interval
+	// between the reconnection attempts made by the supervisor (1 s, 5 min).
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// CloseConns causes the supervisor to close all connections when the
+	// link to the AP is lost (AT+CIPCLOSE) so the pending reads return
+	// io.EOF immediately.
+	CloseConns bool
+
+	// LinkLost is an optional callback called when the link to the AP is
+	// lost. It's called by the supervisor goroutine so it must not block.
+	LinkLost func()
+}
+
+// Supervisor maintains the station connection to the AP. It watches the
+// Wi-Fi events and if the firmware fails to reconnect after the link loss it
+// tries to join the AP itself with the exponential backoff. Use Supervise to
+// create a supervisor.
+type Supervisor struct {
+	d    *espat.Device
+	cfg  SupervisorConfig
+	stop chan struct{}
+	done chan struct{}
+
+	mx        sync.Mutex
+	state     StationState
+	connected chan struct{} // closed in the GotIP state
+}
+
+// Supervise configures the firmware reconnection and starts a goroutine that
+// supervises the station connection of d.
+func Supervise(d *espat.Device, cfg SupervisorConfig) (*Supervisor, error) {
+	if cfg.ReconnInterval <= 0 {
+		cfg.ReconnInterval = time.Second
+	}
+	if cfg.ReconnRepeat == 0 {
+		cfg.ReconnRepeat = 60
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if !d.Legacy() {
+		interval, repeat := int(cfg.ReconnInterval/time.Second), cfg.ReconnRepeat
+		if repeat < 0 {
+			interval, repeat = 0, 0
+		}
+		if _, err := (cmds.CWRECONNCFG{Interval: interval, RepeatCount: repeat}).Set(d); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.AutoConnect != nil {
+		if _, err := (cmds.CWAUTOCONN{Enable: *cfg.AutoConnect}).Set(d); err != nil {
+			return nil, err
+		}
+	}
+	s := &Supervisor{
+		d:         d,
+		cfg:       cfg,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		state:     Disconnected,
+		connected: make(chan struct{}),
+	}
+	events := make(chan espat.Async, 8)
+	d.Notify(events)
+	st, err := State(d)
+	if err != nil {
+		d.StopNotify(events)
+		return nil, err
+	}
+	s.setState(st.State)
+	go s.run(events)
+	return s, nil
+}
+
+// State returns the current state of the station connection.
+func (s *Supervisor) State() StationState {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.state
+}
+
+// WaitConnected waits until the station is connected to the AP and has the IP
+// address or ctx is done.
+func (s *Supervisor) WaitConnected(ctx context.Context) error {
+	s.mx.Lock()
+	connected := s.connected
+	s.mx.Unlock()
+	select {
+	case <-connected:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops the supervisor goroutine and waits for its termination.
+func (s *Supervisor) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Supervisor) setState(state StationState) (prev StationState) {
+	s.mx.Lock()
+	prev = s.state
+	s.state = state
+	if state == GotIP && prev != GotIP {
+		close(s.connected)
+	} else if state != GotIP && prev == GotIP {
+		s.connected = make(chan struct{})
+	}
+	s.mx.Unlock()
+	return prev
+}
+
+// grace returns the time the firmware needs to reconnect.
+func (s *Supervisor) grace() time.Duration {
+	if s.cfg.ReconnRepeat < 0 {
+		return 0
+	}
+	return s.cfg.ReconnInterval*time.Duration(s.cfg.ReconnRepeat) + 5*time.Second
+}
+
+func (s *Supervisor) linkLost() {
+	if s.cfg.CloseConns {
+		if s.d.MultiConn() {
+			s.d.Cmd("+CIPCLOSE=5")
+		} else {
+			s.d.Cmd("+CIPCLOSE")
+		}
+	}
+	if s.cfg.LinkLost != nil {
+		s.cfg.LinkLost()
+	}
+}
+
+const joinTimeout = 20 * time.Second
+
+func (s *Supervisor) run(events chan espat.Async) {
+	defer close(s.done)
+	defer s.d.StopNotify(events)
+	armed := s.State() != GotIP && s.cfg.AP != nil
+	retry := time.NewTimer(0)
+	if !armed {
+		<-retry.C // stopped timer
+	}
+	arm := func(d time.Duration) {
+		if armed && !retry.Stop() {
+			<-retry.C
+		}
+		retry.Reset(d)
+		armed = true
+	}
+	disarm := func() {
+		if armed && !retry.Stop() {
+			<-retry.C
+		}
+		armed = false
+	}
+	backoff := s.cfg.MinBackoff
+	for {
+		select {
+		case <-s.stop:
+			disarm()
+			return
+		case msg := <-events:
+			switch msg.Str {
+			case "WIFI GOT IP":
+				s.setState(GotIP)
+				disarm()
+				backoff = s.cfg.MinBackoff
+			case "WIFI CONNECTED":
+				if s.State() != GotIP {
+					s.setState(Connected)
+				}
+			case "WIFI DISCONNECT":
+				prev := s.setState(Disconnected)
+				if prev == Connected || prev == GotIP {
+					s.linkLost()
+				}
+				if s.cfg.AP != nil && !armed {
+					arm(s.grace())
+				}
+			}
+		case <-retry.C:
+			armed = false
+			if s.State() == GotIP {
+				continue
+			}
+			s.setState(Connecting)
+			ctx, cancel := context.WithTimeout(context.Background(), joinTimeout)
+			err := Join(ctx, s.d, s.cfg.AP)
+			cancel()
+			if err == nil {
+				s.setState(GotIP)
+				backoff = s.cfg.MinBackoff
+				continue
+			}
+			s.setState(Disconnected)
+			arm(backoff)
+			if backoff *= 2; backoff > s.cfg.MaxBackoff {
+				backoff = s.cfg.MaxBackoff
+			}
+		}
+	}
+}
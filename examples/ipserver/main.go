@@ -6,12 +6,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/embeddedgo/espat"
+	"github.com/embeddedgo/espat/espwifi"
 	"github.com/ziutek/serial"
 )
 
@@ -67,15 +70,20 @@ func main() {
 	d := espat.NewDevice("esp0", uart, uart)
 	fatalErr(d.Init(*fr))
 
-	if *fr {
-		for msg := range d.Async() {
-			fatalErr(msg.Err)
-			fmt.Println(msg.Str)
-			if msg.Str == "WIFI GOT IP" {
-				break
-			}
-		}
+	// Wait for an IP address. After the reset the connection to the AP may
+	// take a long time so wait without limit.
+	ctx := context.Background()
+	if !*fr {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+	}
+	ipcfg, err := espwifi.WaitForIP(ctx, d)
+	if err != nil {
+		fmt.Println("Cannot obtain an IP address:", err)
+		os.Exit(1)
 	}
+	fmt.Println("IP address:", ipcfg.Addr)
 
 	_, err = d.Cmd("+CIPMUX=1")
 	fatalErr(err)
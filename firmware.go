@@ -0,0 +1,115 @@
+package espat
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// maxLinks is the number of connections supported by the ESP-AT firmware.
+const maxLinks = 5
+
+type firmware struct {
+	version string // AT core version, e.g. "2.2.0.0"
+	legacy  bool   // 1.x (NONOS) firmware
+}
+
+// detectFirmware parses the AT+GMR response.
+func detectFirmware(resp *Response) *firmware {
+	fw := new(firmware)
+	for _, line := range resp.Values("") {
+		const prefix = "AT version:"
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		v := line[len(prefix):]
+		if i := strings.IndexByte(v, '('); i >= 0 {
+			v = v[:i]
+		}
+		fw.version = v
+		if i := strings.IndexByte(v, '.'); i >= 0 {
+			v = v[:i]
+		}
+		major, err := strconv.Atoi(v)
+		fw.legacy = err == nil && major < 2
+		break
+	}
+	return fw
+}
+
+// Version returns the AT core version (e.g. "2.2.0.0") detected by Init.
+func (d *Device) Version() string {
+	if fw := d.fw.Load(); fw != nil {
+		return fw.version
+	}
+	return ""
+}
+
+// Legacy reports whether Init detected the legacy 1.x (NONOS) ESP8266 AT
+// firmware. The legacy firmware doesn't support some commands (e.g.
+// +CIPSTARTEX, +SYSLOG, +CIPTCPOPT) so the code that uses the device should
+// use the alternatives.
+func (d *Device) Legacy() bool {
+	fw := d.fw.Load()
+	return fw != nil && fw.legacy
+}
+
+// MultiConn reports whether the multiple connection mode was enabled by the
+// last successful CIPMUX command.
+func (d *Device) MultiConn() bool {
+	return d.receiver.mux.Load()
+}
+
+// FreeLink returns the lowest unused connection ID or -1 if all connections
+// are in use.
+func (d *Device) FreeLink() int {
+	used := d.receiver.used.Load()
+	for i := 0; i < maxLinks; i++ {
+		if used&(1<<i) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// FreeLinks returns the number of unused connection IDs. In the single
+// connection mode it returns 1 if the connection is unused or 0 otherwise.
+func (d *Device) FreeLinks() int {
+	n := maxLinks
+	if !d.MultiConn() {
+		n = 1
+	}
+	used := d.receiver.used.Load()
+	free := 0
+	for i := 0; i < n; i++ {
+		if used&(1<<i) == 0 {
+			free++
+		}
+	}
+	return free
+}
+
+// LinkFreed returns a channel that is closed when the next connection is
+// closed and its ID becomes unused. The device restart frees all connection
+// IDs.
+func (d *Device) LinkFreed() <-chan struct{} {
+	return *d.receiver.freed.Load()
+}
+
+// AcquireDial waits for the turn of the dial that waits for a free connection
+// ID (see espn.DialDevContext). Such dials are served one by one. AcquireDial
+// returns ctx.Err() if ctx is done before. Every successful AcquireDial must be
+// followed by ReleaseDial.
+func (d *Device) AcquireDial(ctx context.Context) error {
+	select {
+	case d.dialq <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReleaseDial ends the turn obtained by AcquireDial.
+func (d *Device) ReleaseDial() {
+	<-d.dialq
+}
@@ -0,0 +1,98 @@
+package espat
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+)
+
+// A LineHandler handles an unsolicited line received from the ESP-AT device.
+// The line is passed without the trailing CRLF. For the lines with payload
+// the line contains only the header (the part before ':' that ends the
+// payload length) and the payload contains the data that follows it.
+//
+// Handlers are called by the receiver goroutine so they must not execute any
+// device commands (it would cause a deadlock) and should return quickly.
+type LineHandler func(line string, payload []byte)
+
+type lineHandler struct {
+	prefix  string
+	payload bool
+	h       LineHandler
+}
+
+// Handle registers the handler for the lines that start with the prefix. It
+// allows to support custom AT firmware extensions that emit their own
+// unsolicited lines (e.g. "+SENSOR:..."). The handled lines are not added to
+// the response of the currently executed command. Only the +IPD, +CIPRECVDATA
+// and +SYSMFG lines are handled before the registered handlers so avoid
+// prefixes that collide with other standard messages.
+//
+// If payload is true the line is expected to be followed by a binary payload
+// in the +IPD like form: "<prefix>[<args>,]<length>:<payload>".
+//
+// Handle replaces the handler previously registered for the same prefix.
+// Handle(prefix, false, nil) unregisters the handler.
+func (d *Device) Handle(prefix string, payload bool, h LineHandler) {
+	rcv := &d.receiver
+	rcv.hmx.Lock()
+	var hs []lineHandler
+	if p := rcv.handlers.Load(); p != nil {
+		hs = make([]lineHandler, 0, len(*p)+1)
+		for _, lh := range *p {
+			if lh.prefix != prefix {
+				hs = append(hs, lh)
+			}
+		}
+	}
+	if h != nil {
+		hs = append(hs, lineHandler{prefix, payload, h})
+	}
+	if len(hs) == 0 {
+		rcv.handlers.Store(nil)
+	} else {
+		rcv.handlers.Store(&hs)
+	}
+	rcv.hmx.Unlock()
+}
+
+// handle passes the line to the registered handler. It reports whether the
+// line was handled.
+func (rcv *receiver) handle(line []byte, r *bufio.Reader, full bool) (bool, error) {
+	p := rcv.handlers.Load()
+	if p == nil {
+		return false, nil
+	}
+	for _, lh := range *p {
+		if !bytes.HasPrefix(line, []byte(lh.prefix)) {
+			continue
+		}
+		if !lh.payload {
+			n := len(line)
+			if !full || n < 2 || line[n-2] != '\r' {
+				return false, nil // too long line
+			}
+			lh.h(string(line[:n-2]), nil)
+			return true, nil
+		}
+		k := bytes.IndexByte(line[len(lh.prefix):], ':')
+		if k < 0 {
+			return true, ErrParse
+		}
+		k += len(lh.prefix)
+		i := bytes.LastIndexByte(line[len(lh.prefix):k], ',') + 1
+		i += len(lh.prefix)
+		m, err := strconv.Atoi(string(line[i:k]))
+		if err != nil || m < 0 {
+			return true, ErrParse
+		}
+		hdr := string(line[:k])
+		data := make([]byte, m)
+		if err = readData(line[k+1:], r, data, m); err != nil {
+			return true, ErrParse
+		}
+		lh.h(hdr, data)
+		return true, nil
+	}
+	return false, nil
+}
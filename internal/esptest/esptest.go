@@ -0,0 +1,110 @@
+// Package esptest provides the emulated ESP-AT module for the tests.
+package esptest
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Script maps the command lines (without CRLF) to the module responses. The
+// empty response means no response.
+type Script map[string]string
+
+// Module emulates the ESP-AT module that answers the commands using the
+// script. The unknown commands are reported as the test errors and answered
+// with ERROR. If the response contains the data prompt (the line that starts
+// with '>') the module writes the response up to the prompt, reads n bytes of
+// data (n is the last argument of the command) and then writes the rest of
+// the response.
+type Module struct {
+	t    testing.TB
+	name string
+	cmds chan string
+	w    *io.PipeWriter
+
+	mu     sync.Mutex
+	script Script
+	mute   bool
+}
+
+// New starts the emulated module. It returns the module and the reader and
+// writer that should be passed to espat.NewDevice. The module is stopped at
+// the end of the test.
+func New(t testing.TB, name string, script Script) (m *Module, r io.Reader, w io.Writer) {
+	if script == nil {
+		script = Script{}
+	}
+	cr, mw := io.Pipe()
+	mr, cw := io.Pipe()
+	m = &Module{t: t, name: name, cmds: make(chan string, 64), w: mw, script: script}
+	go m.run(mr)
+	// Don't close mw: the device receiver would report io.EOF forever.
+	t.Cleanup(func() { mr.Close() })
+	return m, cr, cw
+}
+
+func (m *Module) run(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		m.mu.Lock()
+		resp, ok := m.script[line]
+		mute := m.mute
+		m.mu.Unlock()
+		if mute {
+			continue
+		}
+		if !ok {
+			m.t.Errorf("%s: unexpected command %q", m.name, line)
+			resp = "\r\nERROR\r\n"
+		}
+		select {
+		case m.cmds <- line:
+		default:
+		}
+		if i := strings.Index("\n"+resp, "\n>"); i >= 0 {
+			io.WriteString(m.w, resp[:i+1])
+			n, _ := strconv.Atoi(line[strings.LastIndexByte(line, ',')+1:])
+			if _, err := io.ReadFull(br, make([]byte, n)); err != nil {
+				return
+			}
+			resp = resp[i+1:]
+		}
+		io.WriteString(m.w, resp)
+	}
+}
+
+// Cmds returns the channel that receives the command lines answered by the
+// module. The lines that don't fit in the channel buffer are dropped.
+func (m *Module) Cmds() <-chan string {
+	return m.cmds
+}
+
+// Set sets the response to the command.
+func (m *Module) Set(cmd, resp string) {
+	m.mu.Lock()
+	m.script[cmd] = resp
+	m.mu.Unlock()
+}
+
+// SetMute sets the mute mode in which the module reads the commands but
+// doesn't answer them (e.g. to emulate the hung module).
+func (m *Module) SetMute(mute bool) {
+	m.mu.Lock()
+	m.mute = mute
+	m.mu.Unlock()
+}
+
+// Write writes s to the device (e.g. an unsolicited message or the late part
+// of the response).
+func (m *Module) Write(s string) {
+	io.WriteString(m.w, s)
+}
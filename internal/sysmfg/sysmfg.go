@@ -0,0 +1,44 @@
+// Package sysmfg contains the AT+SYSMFG helpers shared by the espat and espd
+// packages.
+package sysmfg
+
+import "strconv"
+
+// Payload checks if the line that starts with +SYSMFG: contains the string
+// or binary value:
+//
+//	+SYSMFG:<"namespace">,<"key">,<type>,<length>,<value>
+//
+// If yes it returns the index of the value in line and its length. Otherwise
+// it returns -1, 0. The value may contain any bytes (including '\n') so it
+// must be handled using its length.
+func Payload(line string) (k, m int) {
+	var commas [4]int
+	n := 0
+	quoted := false
+	for i := 8; i < len(line) && n < len(commas); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				commas[n] = i
+				n++
+			}
+		case '\r', '\n':
+			return -1, 0
+		}
+	}
+	if n != len(commas) {
+		return -1, 0
+	}
+	typ := line[commas[1]+1 : commas[2]]
+	if typ != "9" && typ != "10" {
+		return -1, 0
+	}
+	m, err := strconv.Atoi(line[commas[2]+1 : commas[3]])
+	if err != nil || m < 0 {
+		return -1, 0
+	}
+	return commas[3] + 1, m
+}
@@ -0,0 +1,215 @@
+package espat
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// HealthState represents the state of the link to the ESP-AT device as seen
+// by the Monitor.
+type HealthState int8
+
+const (
+	Healthy      HealthState = iota // device responds to commands
+	Unresponsive                    // the probe command timed out
+	SoftReset                       // recovering using Reinit(true)
+	HardReset                       // recovering using the hard reset callback
+	Failed                          // all recovery attempts failed
+)
+
+var healthStates = [...]string{
+	Healthy:      "healthy",
+	Unresponsive: "unresponsive",
+	SoftReset:    "soft reset",
+	HardReset:    "hard reset",
+	Failed:       "failed",
+}
+
+func (s HealthState) String() string {
+	if uint(s) < uint(len(healthStates)) {
+		return healthStates[s]
+	}
+	return "unknown"
+}
+
+// HealthEvent is sent by the Monitor on every state transition. Err contains
+// the error that caused the transition (if any).
+type HealthEvent struct {
+	State HealthState
+	Err   error
+}
+
+// MonitorConfig contains the Monitor parameters. The zero values are replaced
+// by defaults.
+type MonitorConfig struct {
+	Interval  time.Duration // idle time before the probe is sent (5 s)
+	Timeout   time.Duration // probe timeout (1 s)
+	MaxFails  int           // consecutive probe timeouts before recovery (3)
+	HardReset func() error  // optional hard reset callback (e.g. pulse EN pin)
+}
+
+// Monitor periodically checks if the ESP-AT device responds to commands and
+// tries to recover it if not. Use StartMonitor to create a monitor.
+type Monitor struct {
+	d      *Device
+	cfg    MonitorConfig
+	events chan HealthEvent
+	stop   chan struct{}
+
+	mx    sync.Mutex
+	state HealthState
+	setup []func(d *Device) error
+}
+
+// StartMonitor starts a goroutine that monitors d. The monitor sends the ATE0
+// probe command (the bare AT cannot be sent using Cmd because the empty name
+// means waiting for the SEND OK) if the device was idle for cfg.Interval. The
+// device in the LightSleep or DeepSleep mode isn't probed. After cfg.MaxFails
+// consecutive timeouts it escalates: first it tries Reinit(true), next the
+// cfg.HardReset callback followed by Reinit(true). If both fail the device is
+// reported as failed. After a successful recovery all functions registered
+// using the Register method are called. Note that Reinit(true) discards the
+// pending asynchronous messages.
+func StartMonitor(d *Device, cfg MonitorConfig) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.MaxFails <= 0 {
+		cfg.MaxFails = 3
+	}
+	m := &Monitor{
+		d:      d,
+		cfg:    cfg,
+		events: make(chan HealthEvent, 4),
+		stop:   make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+// Register registers the function that restores the device configuration
+// (e.g. CIPMUX, CIPRECVMODE, Wi-Fi join) after recovery. The functions are
+// called in the order of registration.
+func (m *Monitor) Register(setup func(d *Device) error) {
+	m.mx.Lock()
+	m.setup = append(m.setup, setup)
+	m.mx.Unlock()
+}
+
+// Events returns the channel that can be used to receive state transitions.
+// If the channel is full the oldest event is dropped.
+func (m *Monitor) Events() <-chan HealthEvent {
+	return m.events
+}
+
+// State returns the current state.
+func (m *Monitor) State() HealthState {
+	m.mx.Lock()
+	s := m.state
+	m.mx.Unlock()
+	return s
+}
+
+// Stop stops the monitor.
+func (m *Monitor) Stop() {
+	close(m.stop)
+}
+
+func (m *Monitor) setState(s HealthState, err error) {
+	m.mx.Lock()
+	if m.state == s && err == nil {
+		m.mx.Unlock()
+		return
+	}
+	m.state = s
+	m.mx.Unlock()
+	ev := HealthEvent{s, err}
+	for {
+		select {
+		case m.events <- ev:
+			return
+		default:
+		}
+		select {
+		case <-m.events: // drop the oldest event
+		default:
+		}
+	}
+}
+
+func (m *Monitor) loop() {
+	d := m.d
+	fails := 0
+	tim := time.NewTimer(m.cfg.Interval)
+	defer tim.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-tim.C:
+		}
+		idle := time.Duration(0)
+		if t := d.stats.lastResp.Load(); t != 0 {
+			idle = time.Since(time.Unix(0, t))
+		}
+		if idle < m.cfg.Interval && fails == 0 {
+			tim.Reset(m.cfg.Interval - idle)
+			continue
+		}
+		tim.Reset(m.cfg.Interval)
+		if SleepMode(d.sleep.mode.Load()) != NoSleep {
+			continue // the probe would wake up the device
+		}
+		_, err := d.CmdTimeout(m.cfg.Timeout, "E0")
+		if err == nil {
+			fails = 0
+			m.setState(Healthy, nil)
+			continue
+		}
+		if !errors.Is(err, ErrTimeout) {
+			continue // the device responds
+		}
+		fails++
+		if m.State() == Failed {
+			continue
+		}
+		m.setState(Unresponsive, err)
+		if fails < m.cfg.MaxFails {
+			continue
+		}
+		if err = m.recover(); err != nil {
+			m.setState(Failed, err)
+			continue
+		}
+		fails = 0
+		m.setState(Healthy, nil)
+	}
+}
+
+func (m *Monitor) recover() error {
+	d := m.d
+	m.setState(SoftReset, nil)
+	err := d.Reinit(true)
+	if err != nil && m.cfg.HardReset != nil {
+		m.setState(HardReset, err)
+		if err = m.cfg.HardReset(); err == nil {
+			err = d.Reinit(true)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m.mx.Lock()
+	setup := m.setup
+	m.mx.Unlock()
+	for _, f := range setup {
+		if err := f(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package espat
+
+// Notify causes the asynchronous messages to be relayed to ch in addition to
+// the Async channel. It allows many goroutines to observe the device events
+// (e.g. "WIFI DISCONNECT"). The messages are sent to ch without blocking so
+// ch should be buffered. The messages that don't fit in ch are dropped.
+// Notify(ch) can be called many times with different channels.
+func (d *Device) Notify(ch chan<- Async) {
+	rcv := &d.receiver
+	rcv.hmx.Lock()
+	var chs []chan<- Async
+	if p := rcv.notify.Load(); p != nil {
+		chs = append(chs, *p...)
+	}
+	chs = append(chs, ch)
+	rcv.notify.Store(&chs)
+	rcv.hmx.Unlock()
+}
+
+// StopNotify stops relaying the asynchronous messages to ch.
+func (d *Device) StopNotify(ch chan<- Async) {
+	rcv := &d.receiver
+	rcv.hmx.Lock()
+	if p := rcv.notify.Load(); p != nil {
+		var chs []chan<- Async
+		for _, c := range *p {
+			if c != ch {
+				chs = append(chs, c)
+			}
+		}
+		if len(chs) == 0 {
+			rcv.notify.Store(nil)
+		} else {
+			rcv.notify.Store(&chs)
+		}
+	}
+	rcv.hmx.Unlock()
+}
+
+// relay is called by the receiver goroutine.
+func (rcv *receiver) relay(msg Async) {
+	p := rcv.notify.Load()
+	if p == nil {
+		return
+	}
+	for _, ch := range *p {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package espat
+
+// SplitArgs splits the comma separated list of AT command response arguments
+// (e.g. `1,"a\"b",,-2`). The quoted strings are unquoted and unescaped. The
+// unquoted arguments are returned as is. SplitArgs("") returns nil.
+func SplitArgs(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var args []string
+	for {
+		var arg string
+		if s != "" && s[0] == '"' {
+			arg, s = unquote(s[1:])
+		} else {
+			i := 0
+			for i < len(s) && s[i] != ',' {
+				i++
+			}
+			arg, s = s[:i], s[i:]
+		}
+		args = append(args, arg)
+		if s == "" {
+			return args
+		}
+		s = s[1:] // skip comma
+	}
+}
+
+// unquote returns the unescaped content of the quoted string and the rest of
+// s that starts right after the closing quote (and any garbage before the next
+// comma).
+func unquote(s string) (arg, rest string) {
+	esc := false
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			esc = true
+			i++
+			continue
+		}
+		if c == '"' {
+			break
+		}
+	}
+	arg = s[:i]
+	if esc {
+		buf := make([]byte, 0, len(arg))
+		for k := 0; k < len(arg); k++ {
+			if arg[k] == '\\' && k+1 < len(arg) {
+				k++
+			}
+			buf = append(buf, arg[k])
+		}
+		arg = string(buf)
+	}
+	for i < len(s) && s[i] != ',' {
+		i++
+	}
+	return arg, s[i:]
+}
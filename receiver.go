@@ -2,10 +2,15 @@ package espat
 
 import (
 	"bufio"
+	"bytes"
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/embeddedgo/espat/internal/sysmfg"
 )
 
 // Conn represents a TCP or UDP connection.
@@ -17,9 +22,19 @@ import (
 // or informs about the availability of new data (returning nil) in passive
 // receive mode.
 type Conn struct {
-	Dev *Device
-	ID  int           // connection ID or -1
-	Ch  <-chan []byte // receive channel
+	Dev  *Device
+	ID   int           // connection ID or -1
+	Ch   <-chan []byte // receive channel
+	Link *LinkInfo     // connection details (requires SysMsgLinkConn) or nil
+}
+
+// LinkInfo contains the connection details reported by the +LINK_CONN message.
+type LinkInfo struct {
+	Proto      string // "TCP", "UDP", "SSL", "TCPv6", "UDPv6", "SSLv6"
+	Server     bool   // connection accepted by the server
+	RemoteIP   string
+	RemotePort int
+	LocalPort  int
 }
 
 // Async represents an asynchronous message from the ESP-AT device or
@@ -43,11 +58,28 @@ type receiver struct {
 	async  chan Async
 	server atomic.Pointer[chan *Conn]
 	conns  [maxConns]chan []byte
+	sysmsg atomic.Uint32 // SysMsg
+	mux    atomic.Bool   // multiple connection mode
+	used   atomic.Uint32 // bitmask of used connection IDs
+	freed  atomic.Pointer[chan struct{}]
+
+	// rebooted is closed when the ready message is received
+	rebooted atomic.Pointer[chan struct{}]
+
+	hmx      sync.Mutex
+	handlers atomic.Pointer[[]lineHandler]
+	notify   atomic.Pointer[[]chan<- Async]
+
+	boot atomic.Pointer[BootInfo]
 }
 
 func receiverInit(rcv *receiver) {
 	rcv.cmd = make(chan *cmd)
 	rcv.async = make(chan Async, 5)
+	freed := make(chan struct{})
+	rcv.freed.Store(&freed)
+	rebooted := make(chan struct{})
+	rcv.rebooted.Store(&rebooted)
 }
 
 func receiverLoop(dev *Device, inp io.Reader) {
@@ -58,6 +90,7 @@ func receiverLoop(dev *Device, inp io.Reader) {
 		rerr  error
 	)
 	rcv := &dev.receiver
+	st := &dev.stats
 	r := bufio.NewReaderSize(inp, 128)
 	dev.cmdx.Unlock()
 	for {
@@ -67,6 +100,7 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			rerr = err
 			goto sendAsync
 		}
+		st.lastResp.Store(time.Now().UnixNano())
 		switch {
 		case len(line) >= 7 && string(line[:5]) == "+IPD,":
 			ci := 0
@@ -82,8 +116,7 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			}
 			conn := rcv.conns[ci]
 			if conn == nil {
-				rerr = ErrUnkConn
-				goto sendAsync
+				rerr = ErrUnkConn // the data must be read anyway
 			}
 			recvmode := -1
 			k := i + 1
@@ -101,28 +134,46 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			case 0: // active
 				m, _ := strconv.Atoi(string(line[i:k]))
 				if m <= 0 {
-					close(conn) // avoid deadlock
+					if conn != nil {
+						close(conn) // avoid deadlock
+					}
 					rerr = ErrParse
 					goto sendAsync
 				}
-				pkt := make([]byte, m)
+				var pkt []byte
+				if conn != nil {
+					pkt = make([]byte, m)
+				}
 				if err = readData(line[k+1:], r, pkt, m); err != nil {
 					rerr = ErrParse
 					goto sendAsync
 				}
+				st.recv(ci, m, true)
+				if conn == nil {
+					goto sendAsync
+				}
 				conn <- pkt
 				continue
 			case 1: // passive
+				st.recv(ci, 0, true)
+				if conn == nil {
+					goto sendAsync
+				}
 				conn <- nil
 				continue
 			default:
 				rerr = ErrParse
 				goto sendAsync
 			}
-		case len(line) > 15 && string(line[:13]) == "+CIPRECVDATA:":
+		case len(line) > 15 && string(line[:12]) == "+CIPRECVDATA" &&
+			(line[12] == ':' || line[12] == ','):
+			sep := byte(',')
+			if line[12] == ',' {
+				sep = ':' // legacy firmware: +CIPRECVDATA,<len>:<data>
+			}
 			k := 14
 			for ; k < len(line); k++ {
-				if line[k] == ',' {
+				if line[k] == sep {
 					break
 				}
 			}
@@ -137,21 +188,56 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			}
 			cmd := <-rcv.cmd
 			var buf []byte
-			if len(cmd.args) != 0 {
+			if len(cmd.args) != 0 && !cmd.abandoned {
 				buf, _ = cmd.args[0].([]byte)
 			}
 			if len(buf) > m {
 				buf = buf[:m] // readData requires len(buf) <= m
 			}
-			if err = readData(line[k+1:], r, buf, m); err != nil {
-				cmd.err = err
-			} else if _, err = r.ReadSlice('\n'); err != nil {
+			if err = readData(line[k+1:], r, buf, m); err == nil {
+				_, err = r.ReadSlice('\n')
+			}
+			if err == nil {
+				ci := 0
+				if len(cmd.args) == 3 {
+					ci, _ = cmd.args[1].(int)
+				}
+				if uint(ci) < maxConns {
+					st.recv(ci, m, false)
+				}
+			}
+			if cmd.abandoned {
+				continue // discard the late response
+			}
+			if err != nil {
 				cmd.err = err
 			} else {
 				cmd.resp.Int = len(buf)
 			}
+			st.cmdDone(cmd)
 			cmd.ready.Unlock()
 			continue
+		case len(line) > 8 && string(line[:8]) == "+SYSMFG:":
+			k, m := sysmfg.Payload(string(line))
+			if k < 0 {
+				break // no binary payload, handle as a normal line
+			}
+			sb.Write(line[:k])
+			data := make([]byte, m)
+			if err = readData(line[k:], r, data, m); err != nil {
+				rerr = ErrParse
+				goto sendAsync
+			}
+			sb.Write(data)
+			sb.WriteByte('\n')
+			continue
+		}
+		if ok, herr := rcv.handle(line, r, err == nil); ok {
+			if herr != nil {
+				rerr = herr
+				goto sendAsync
+			}
+			continue
 		}
 		if n := len(line); err == bufio.ErrBufferFull || n < 2 || line[n-2] != '\r' {
 			sb.Write(line)
@@ -164,8 +250,11 @@ func receiverLoop(dev *Device, inp io.Reader) {
 		}
 		if emptl {
 			emptl = false
-			if ok := string(line) == "OK"; ok || string(line) == "ERROR" {
+			// The "no change" and "FAIL" are used by the legacy firmware.
+			ok := string(line) == "OK" || string(line) == "no change"
+			if ok || string(line) == "ERROR" || string(line) == "FAIL" {
 				s := sb.String()
+				resp.Lines = splitLines(s)
 				if ok {
 					if s != "" {
 						resp.Str = s
@@ -181,15 +270,20 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			if ok := string(line) == "SEND OK"; ok || string(line) == "SEND FAIL" {
 				if !ok {
 					rerr = ErrTimeout // BUG? is "SEND FAIL" always a timeout?
+					st.inc(&st.sendFail)
 				}
 				goto sendResp
 			}
 		}
 		switch {
 		case string(line) == ">":
-			// skip a prompt sign
+			// Skip a prompt sign. It isn't followed by CRLF so the CRLF
+			// that precedes the response to the data is read with it.
+			emptl = true
 		case len(line) >= 12 && string(line[:5]) == "Recv ":
 			// skip ESP-AT confirmation of data receipt
+		case len(line) > 5 && string(line[:5]) == "busy ":
+			// skip "busy p..." and "busy s..." (legacy firmware)
 		case string(line) == "CONNECT" || string(line) == "CLOSED" ||
 			string(line[1:]) == ",CONNECT" || string(line[1:]) == ",CLOSED":
 			id := -1
@@ -205,26 +299,59 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			}
 			if line[len(line)-1] == 'T' {
 				// CONNECT
-				ch := make(chan []byte, 3)
-				rcv.conns[ci] = ch
-				conn := &Conn{dev, id, ch}
-				if srv := rcv.server.Load(); srv != nil {
-					*srv <- conn
-				} else {
+				if conn := rcv.connect(dev, id, nil); conn != nil {
 					resp.Conn = conn
 				}
 			} else {
 				// CLOSED
-				ch := rcv.conns[ci]
-				if ch != nil {
+				if ch := rcv.conns[ci]; ch != nil {
 					close(ch)
 					rcv.conns[ci] = nil
 				}
+				if rcv.used.Load()&(1<<ci) != 0 {
+					rcv.setUsed(ci, false)
+				}
+			}
+		case len(line) > 11 && string(line[:11]) == "+LINK_CONN:" &&
+			SysMsg(rcv.sysmsg.Load())&SysMsgLinkConn != 0:
+			id, link, err := parseLinkConn(string(line[11:]))
+			if err != nil {
+				rerr = err
+				goto sendAsync
 			}
+			if link != nil {
+				if !rcv.mux.Load() {
+					id = -1
+				}
+				if conn := rcv.connect(dev, id, link); conn != nil {
+					resp.Conn = conn
+				}
+			}
+		case string(line) == "+QUITT":
+			goto sendAsync
+		case len(line) > 6 && string(line[:6]) == "rst:0x" ||
+			bytes.Contains(line, []byte("rst cause:")):
+			if bi := parseBootBanner(string(line)); bi != nil {
+				rcv.boot.Store(bi)
+			}
+			goto sendAsync
+		case len(line) > 4 && string(line[:4]) == "ets ":
+			// skip the first line of the ESP32 boot banner
 		case string(line) == "ready":
+			rebooted := make(chan struct{})
+			close(*rcv.rebooted.Swap(&rebooted))
+			rcv.reset()
+			if dev.wokenUp() {
+				continue // deep sleep wakeup
+			}
 			goto sendAsync
 		case len(line) > 5 && string(line[:5]) == "WIFI ":
 			goto sendAsync
+		case bytes.HasPrefix(line, []byte("+STA_CONNECTED:")) ||
+			bytes.HasPrefix(line, []byte("+STA_DISCONNECTED:")) ||
+			bytes.HasPrefix(line, []byte("+DIST_STA_IP:")):
+			// SoftAP station events
+			goto sendAsync
 		default:
 			sb.Grow(len(line) + 1)
 			sb.Write(line)
@@ -234,9 +361,21 @@ func receiverLoop(dev *Device, inp io.Reader) {
 	sendResp:
 		{
 			cmd := <-rcv.cmd
-			cmd.resp = resp
-			cmd.err = rerr
-			cmd.ready.Unlock()
+			if rerr == nil && len(cmd.name) >= 8 && cmd.name[:8] == "+CIPMUX=" {
+				rcv.mux.Store(muxArg(cmd))
+			}
+			if cmd.abandoned {
+				// Discard the late response. Nobody will read the
+				// connection opened by the timed out command.
+				if c := resp.Conn; c != nil {
+					rcv.drop(c)
+				}
+			} else {
+				cmd.resp = resp
+				cmd.err = rerr
+				st.cmdDone(cmd)
+				cmd.ready.Unlock()
+			}
 			resp = Response{}
 			rerr = nil
 			sb.Reset()
@@ -246,6 +385,7 @@ func receiverLoop(dev *Device, inp io.Reader) {
 		{
 			msg := Async{string(line), rerr}
 			rerr = nil
+			rcv.relay(msg)
 			overrun := false
 		again:
 			select {
@@ -260,6 +400,7 @@ func receiverLoop(dev *Device, inp io.Reader) {
 			}
 			if !overrun {
 				overrun = true
+				st.inc(&st.overruns)
 				rcv.async <- Async{} // inform about an overrun
 			}
 			goto again
@@ -267,6 +408,80 @@ func receiverLoop(dev *Device, inp io.Reader) {
 	}
 }
 
+// connect registers a new connection. It returns nil if the connection was
+// passed to the server channel.
+func (rcv *receiver) connect(dev *Device, id int, link *LinkInfo) *Conn {
+	ci := id
+	if ci < 0 {
+		ci = 0
+	}
+	ch := make(chan []byte, 3)
+	rcv.conns[ci] = ch
+	rcv.setUsed(ci, true)
+	conn := &Conn{dev, id, ch, link}
+	if srv := rcv.server.Load(); srv != nil {
+		*srv <- conn
+		return nil
+	}
+	return conn
+}
+
+// drop unregisters the connection. The connection ID remains used until the
+// CLOSED message is received. It's called only by the receiver goroutine.
+func (rcv *receiver) drop(c *Conn) {
+	ci := c.ID
+	if ci < 0 {
+		ci = 0
+	}
+	if ch := rcv.conns[ci]; ch != nil {
+		close(ch)
+		rcv.conns[ci] = nil
+	}
+}
+
+// reset closes all connections and clears the link state after the device
+// restart. It's called only by the receiver goroutine.
+func (rcv *receiver) reset() {
+	for ci, ch := range rcv.conns {
+		if ch != nil {
+			close(ch)
+			rcv.conns[ci] = nil
+		}
+	}
+	rcv.mux.Store(false)
+	rcv.used.Store(0)
+	freed := make(chan struct{})
+	close(*rcv.freed.Swap(&freed))
+}
+
+// setUsed is called only by the receiver goroutine.
+func (rcv *receiver) setUsed(ci int, used bool) {
+	u := rcv.used.Load()
+	if used {
+		u |= 1 << ci
+	} else {
+		u &^= 1 << ci
+	}
+	rcv.used.Store(u)
+	if !used {
+		freed := make(chan struct{})
+		close(*rcv.freed.Swap(&freed))
+	}
+}
+
+// muxArg returns the multiple connection mode set by the CIPMUX command that
+// may be provided in the name ("+CIPMUX=1") or as an argument.
+func muxArg(cmd *cmd) bool {
+	if len(cmd.name) > 8 {
+		return cmd.name[8] == '1'
+	}
+	if len(cmd.args) != 0 {
+		a, _ := cmd.args[0].(int)
+		return a == 1
+	}
+	return false
+}
+
 // readData reads m bytes from the preread and r. The first len(buf) read bytes
 // are placed into buf. len(buf) must be <= m.
 func readData(preread []byte, r *bufio.Reader, buf []byte, m int) error {
@@ -0,0 +1,208 @@
+package espat
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SleepMode represents the ESP-AT sleep mode.
+type SleepMode int32
+
+const (
+	NoSleep          SleepMode = 0 // AT+SLEEP=0, disable sleep
+	ModemSleepDTIM   SleepMode = 1 // AT+SLEEP=1, modem sleep by DTIM
+	LightSleep       SleepMode = 2 // AT+SLEEP=2, light sleep
+	ModemSleepListen SleepMode = 3 // AT+SLEEP=3, modem sleep by listen interval
+	DeepSleep        SleepMode = 4 // AT+GSLP, deep sleep
+)
+
+// WakeSource represents the wakeup source of the light sleep mode.
+type WakeSource int8
+
+const (
+	WakeNone  WakeSource = -1 // don't configure the wakeup source
+	WakeTimer WakeSource = 0  // wake up after the Wake.Time
+	WakeUART  WakeSource = 1  // wake up by the UART Wake.Num
+	WakeGPIO  WakeSource = 2  // wake up by the GPIO Wake.Num at Wake.Level
+)
+
+// Wake describes how the device wakes up from the light or deep sleep.
+type Wake struct {
+	// Source is the light sleep wakeup source (AT+SLEEPWKCFG). It is ignored
+	// in other sleep modes.
+	Source WakeSource
+
+	// Time is the light sleep time for the WakeTimer source or the deep
+	// sleep time (AT+GSLP). Zero means infinite deep sleep (the device can
+	// be woken up only by reset).
+	Time time.Duration
+
+	Num   int // UART or GPIO number
+	Level int // GPIO wakeup level
+
+	// Reject causes that commands issued when the device sleeps fail with
+	// ErrSleeping instead of being queued until the device wakes up.
+	Reject bool
+}
+
+type sleepState struct {
+	mode   atomic.Int32 // SleepMode
+	reinit atomic.Bool
+	reject bool
+	until  time.Time
+	woken  chan struct{}
+	hook   func() error
+}
+
+// SetWakeHook sets a function that is called before the next command is sent
+// to the sleeping device in the LightSleep or DeepSleep mode. It can be used
+// to toggle the wakeup GPIO or the EN pin.
+func (d *Device) SetWakeHook(hook func() error) {
+	d.cmdx.Lock()
+	d.sleep.hook = hook
+	d.cmdx.Unlock()
+}
+
+// Sleep puts the device into the sleep mode. Wake is required for the
+// DeepSleep mode and optional for the LightSleep mode, ignored for others.
+//
+// In the light sleep mode the wake hook (see SetWakeHook) is called before
+// every command. In the deep sleep mode the commands are queued until the
+// ready message is received from the woken device (see also Wake.Reject). The
+// ready message is not sent to the Async channel in such case and the device
+// is reinitialized as by Init(false).
+func (d *Device) Sleep(mode SleepMode, wake *Wake) error {
+	d.cmdx.Lock()
+	defer d.cmdx.Unlock()
+	if err := d.wake(); err != nil {
+		return d.cmdErr("+SLEEP=", err)
+	}
+	ss := &d.sleep
+	ss.reject = wake != nil && wake.Reject
+	if mode == DeepSleep {
+		if wake == nil {
+			return &Error{d.name, "+GSLP=", ErrArgType}
+		}
+		ms := int(wake.Time / time.Millisecond)
+		ss.until = time.Time{}
+		if ms != 0 {
+			ss.until = time.Now().Add(wake.Time)
+		}
+		ss.woken = make(chan struct{})
+		ss.mode.Store(int32(DeepSleep))
+		if _, err := d.exec("+GSLP=", ms); err != nil {
+			ss.mode.Store(int32(NoSleep))
+			return err
+		}
+		return nil
+	}
+	if mode == LightSleep && wake != nil && wake.Source != WakeNone {
+		var err error
+		switch wake.Source {
+		case WakeTimer:
+			_, err = d.exec("+SLEEPWKCFG=0,", int(wake.Time/time.Millisecond))
+		case WakeUART:
+			_, err = d.exec("+SLEEPWKCFG=1,", wake.Num)
+		default:
+			_, err = d.exec("+SLEEPWKCFG=", int(wake.Source), wake.Num, wake.Level)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := d.exec("+SLEEP=", int(mode)); err != nil {
+		return err
+	}
+	if mode != LightSleep {
+		mode = NoSleep // the device in modem sleep can still receive commands
+	}
+	ss.mode.Store(int32(mode))
+	return nil
+}
+
+// MCUWake describes how the ESP-AT device wakes up the host MCU
+// (AT+USERWKMCUCFG).
+type MCUWake struct {
+	UART  bool          // wake up the MCU using UART instead of GPIO
+	Num   int           // GPIO or UART number
+	Level int           // GPIO wakeup level
+	Delay time.Duration // wait for the MCU before sending data
+}
+
+// SetMCUWake configures the ESP-AT device to wake up the host MCU before
+// sending it any data. Nil w disables this function.
+func (d *Device) SetMCUWake(w *MCUWake) error {
+	if w == nil {
+		_, err := d.Cmd("+USERWKMCUCFG=0")
+		return err
+	}
+	mode := 1
+	if w.UART {
+		mode = 2
+	}
+	_, err := d.Cmd(
+		"+USERWKMCUCFG=1,", mode, w.Num, w.Level,
+		int(w.Delay/time.Millisecond),
+	)
+	return err
+}
+
+// wake is called with locked device before sending any command.
+func (d *Device) wake() error {
+	ss := &d.sleep
+	switch SleepMode(ss.mode.Load()) {
+	case NoSleep:
+		// fast path
+	case LightSleep:
+		if ss.hook != nil {
+			if err := ss.hook(); err != nil {
+				return err
+			}
+		} else if ss.reject {
+			return ErrSleeping
+		}
+	case DeepSleep:
+		if ss.reject {
+			return ErrSleeping
+		}
+		timeout := 2 * time.Second
+		if ss.hook != nil {
+			if err := ss.hook(); err != nil {
+				return err
+			}
+		} else if !ss.until.IsZero() {
+			if t := time.Until(ss.until); t > 0 {
+				timeout += t
+			}
+		} else {
+			timeout = -1 // wait infinitely
+		}
+		var tc <-chan time.Time
+		if timeout >= 0 {
+			tim := time.NewTimer(timeout)
+			defer tim.Stop()
+			tc = tim.C
+		}
+		select {
+		case <-ss.woken:
+		case <-tc:
+			return &Error{d.name, "ready", ErrTimeout}
+		}
+	}
+	if ss.reinit.Swap(false) {
+		return d.setup(d.exec)
+	}
+	return nil
+}
+
+// wokenUp is called by the receiver when the ready message is received. It
+// reports whether the ready message was caused by a deep sleep wakeup.
+func (d *Device) wokenUp() bool {
+	ss := &d.sleep
+	if !ss.mode.CompareAndSwap(int32(DeepSleep), int32(NoSleep)) {
+		return false
+	}
+	ss.reinit.Store(true)
+	close(ss.woken)
+	return true
+}
@@ -0,0 +1,159 @@
+package espat
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets is the number of buckets in the command latency histogram.
+// The i-th bucket counts commands completed in less than 1<<i milliseconds.
+// The last bucket counts all the remaining ones.
+const LatencyBuckets = 16
+
+// CmdStats contains statistics of a single AT command.
+type CmdStats struct {
+	Count   uint                   // number of executed commands
+	Errors  uint                   // number of commands that returned an error
+	Total   time.Duration          // total time spent on executing the command
+	Max     time.Duration          // the longest execution time
+	Latency [LatencyBuckets]uint32 // latency histogram
+}
+
+// LinkStats contains statistics of a single link (connection ID).
+type LinkStats struct {
+	Sent uint64 // number of bytes sent using CIPSEND
+	Recv uint64 // number of bytes received (+IPD or +CIPRECVDATA)
+	IPD  uint   // number of +IPD messages
+}
+
+// Stats is a snapshot of the device statistics. See Device.Stats.
+type Stats struct {
+	Cmds          map[string]CmdStats // statistics by the command name
+	Errors        map[string]uint     // number of ErrorESP errors by the code
+	Links         [maxConns]LinkStats // statistics by the connection ID
+	IPD           uint                // number of +IPD messages
+	SendFail      uint                // number of SEND FAIL responses
+	AsyncOverruns uint                // number of Async channel overruns
+	LastResp      time.Time           // time of the last line received
+}
+
+// SinceLastResp returns the time elapsed since the last line was received
+// from the ESP-AT device.
+func (s *Stats) SinceLastResp() time.Duration {
+	if s.LastResp.IsZero() {
+		return 0
+	}
+	return time.Since(s.LastResp)
+}
+
+type stats struct {
+	mx       sync.Mutex
+	cmds     map[string]*CmdStats
+	errors   map[string]uint
+	links    [maxConns]LinkStats
+	ipd      uint
+	sendFail uint
+	overruns uint
+	lastResp atomic.Int64 // UnixNano
+}
+
+func (s *stats) cmdDone(c *cmd) {
+	lat := time.Since(c.start)
+	s.mx.Lock()
+	if s.cmds == nil {
+		s.cmds = make(map[string]*CmdStats)
+	}
+	cs := s.cmds[c.name]
+	if cs == nil {
+		cs = new(CmdStats)
+		s.cmds[c.name] = cs
+	}
+	cs.Count++
+	cs.Total += lat
+	if lat > cs.Max {
+		cs.Max = lat
+	}
+	i := 0
+	for ms := lat / time.Millisecond; ms != 0 && i < LatencyBuckets-1; ms >>= 1 {
+		i++
+	}
+	cs.Latency[i]++
+	if c.err != nil {
+		cs.Errors++
+		if e, ok := c.err.(*ErrorESP); ok {
+			if s.errors == nil {
+				s.errors = make(map[string]uint)
+			}
+			s.errors[e.Code]++
+		}
+	}
+	s.mx.Unlock()
+}
+
+func (s *stats) recv(ci, n int, ipd bool) {
+	s.mx.Lock()
+	ls := &s.links[ci]
+	ls.Recv += uint64(n)
+	if ipd {
+		ls.IPD++
+		s.ipd++
+	}
+	s.mx.Unlock()
+}
+
+// sent accounts the data length declared by the CIPSEND command. The accepted
+// argument lists are: (length), (length, host, port) in the single connection
+// mode and (id, length), (id, length, host, port) in the multiple connection
+// mode.
+func (s *stats) sent(args []any) {
+	ci, n := 0, 0
+	switch {
+	case len(args) >= 2 && isInt(args[1]):
+		ci, _ = args[0].(int)
+		n = args[1].(int)
+	case len(args) >= 1:
+		n, _ = args[0].(int)
+	}
+	if uint(ci) >= maxConns || n <= 0 {
+		return
+	}
+	s.mx.Lock()
+	s.links[ci].Sent += uint64(n)
+	s.mx.Unlock()
+}
+
+func isInt(a any) bool {
+	_, ok := a.(int)
+	return ok
+}
+
+func (s *stats) inc(cnt *uint) {
+	s.mx.Lock()
+	*cnt++
+	s.mx.Unlock()
+}
+
+// Stats returns a snapshot of the device statistics.
+func (d *Device) Stats() *Stats {
+	s := &d.stats
+	st := new(Stats)
+	s.mx.Lock()
+	st.Cmds = make(map[string]CmdStats, len(s.cmds))
+	for name, cs := range s.cmds {
+		st.Cmds[name] = *cs
+	}
+	st.Errors = make(map[string]uint, len(s.errors))
+	for code, n := range s.errors {
+		st.Errors[code] = n
+	}
+	st.Links = s.links
+	st.IPD = s.ipd
+	st.SendFail = s.sendFail
+	st.AsyncOverruns = s.overruns
+	s.mx.Unlock()
+	if t := s.lastResp.Load(); t != 0 {
+		st.LastResp = time.Unix(0, t)
+	}
+	return st
+}
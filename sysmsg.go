@@ -0,0 +1,134 @@
+package espat
+
+import "strconv"
+
+// SysMsg represents the AT+SYSMSG bits.
+type SysMsg uint8
+
+const (
+	// SysMsgQuit enables the +QUITT message when quitting the passthrough
+	// mode.
+	SysMsgQuit SysMsg = 1 << 0
+
+	// SysMsgLinkConn enables the detailed +LINK_CONN message instead of the
+	// simple "n,CONNECT" one. The receiver provides the connection details in
+	// the Conn.Link field.
+	SysMsgLinkConn SysMsg = 1 << 1
+
+	// SysMsgLinkState enables the CONNECT/CLOSED messages in the passthrough
+	// mode.
+	SysMsgLinkState SysMsg = 1 << 2
+)
+
+// An InitOption represents an additional configuration command executed by
+// Init after the default ones. The options are remembered by the device and
+// applied again on every reinitialization (e.g. after the deep sleep wakeup).
+type InitOption struct {
+	name string
+	args []any
+	data string // data written after the data prompt
+}
+
+// WithSysMsg returns the option that configures the system message format
+// (AT+SYSMSG). The receiver adapts its parsing to the configured format.
+func WithSysMsg(m SysMsg) InitOption {
+	return InitOption{name: "+SYSMSG=", args: []any{int(m)}}
+}
+
+// WithSysStore returns the option that configures whether the configuration
+// changes are stored in the flash (AT+SYSSTORE).
+func WithSysStore(store bool) InitOption {
+	a := 0
+	if store {
+		a = 1
+	}
+	return InitOption{name: "+SYSSTORE=", args: []any{a}}
+}
+
+// WithSysMsgFilter returns the option that adds the system message filter
+// (AT+SYSMSGFILTERCFG). The messages that match both the head and the tail
+// regular expressions are not sent by the device. Use many options to set
+// many filters. The previously configured filters are removed and the
+// filtering is enabled (AT+SYSMSGFILTER=1).
+func WithSysMsgFilter(head, tail string) InitOption {
+	return InitOption{
+		name: "+SYSMSGFILTERCFG=1,",
+		args: []any{len(head), len(tail)},
+		data: head + tail,
+	}
+}
+
+// applyInitOptions is called by setup with a locked device.
+func (d *Device) applyInitOptions(cmd func(string, ...any) (*Response, error)) error {
+	filters := false
+	for _, o := range d.initOpts {
+		if o.data != "" && !filters {
+			if _, err := cmd("+SYSMSGFILTERCFG=0"); err != nil {
+				return err
+			}
+			filters = true
+		}
+		if _, err := cmd(o.name, o.args...); err != nil {
+			return err
+		}
+		if o.data != "" {
+			if _, err := d.UnsafeWriteString(o.data); err != nil {
+				return &Error{d.name, o.name, err}
+			}
+			if _, err := cmd(""); err != nil {
+				return err
+			}
+		}
+		if o.name == "+SYSMSG=" {
+			d.receiver.sysmsg.Store(uint32(o.args[0].(int)))
+		}
+	}
+	if filters {
+		if _, err := cmd("+SYSMSGFILTER=1"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SysStore reports whether the configuration changes are stored in the
+// flash (AT+SYSSTORE?).
+func (d *Device) SysStore() (bool, error) {
+	resp, err := d.Cmd("+SYSSTORE?")
+	if err != nil {
+		return false, err
+	}
+	v, _ := resp.Value("+SYSSTORE")
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return false, &Error{d.name, "+SYSSTORE?", ErrParse}
+	}
+	return n != 0, nil
+}
+
+// parseLinkConn parses the +LINK_CONN message arguments:
+//
+//	<status>,<link_id>,<"type">,<c/s>,<"remote_ip">,<remote_port>,<local_port>
+//
+// It returns nil if the status is not 0 (connection failed).
+func parseLinkConn(s string) (id int, link *LinkInfo, err error) {
+	args := SplitArgs(s)
+	if len(args) < 7 {
+		return -1, nil, ErrParse
+	}
+	id, err = strconv.Atoi(args[1])
+	if err != nil || uint(id) >= maxConns {
+		return -1, nil, ErrParse
+	}
+	if args[0] != "0" {
+		return id, nil, nil
+	}
+	link = &LinkInfo{
+		Proto:    args[2],
+		Server:   args[3] == "1",
+		RemoteIP: args[4],
+	}
+	link.RemotePort, _ = strconv.Atoi(args[5])
+	link.LocalPort, _ = strconv.Atoi(args[6])
+	return id, link, nil
+}
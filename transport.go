@@ -0,0 +1,63 @@
+package espat
+
+import "io"
+
+// Transport represents the serial port that connects the ESP-AT device. In
+// addition to reading and writing data it allows to change the port
+// parameters and to drive the modem control lines that are usually connected
+// to the EN (RTS) and IO0 (DTR) pins of the ESP module.
+type Transport interface {
+	io.Reader
+	io.Writer
+
+	// SetSpeed sets the baudrate.
+	SetSpeed(baud int) error
+
+	// SetFlowControl enables/disables the hardware (RTS/CTS) flow control.
+	SetFlowControl(hw bool) error
+
+	// SetDTR sets the state of the DTR line.
+	SetDTR(on bool) error
+
+	// SetRTS sets the state of the RTS line.
+	SetRTS(on bool) error
+}
+
+// NewDeviceTransport works like NewDevice but uses the transport t for both
+// reading and writing. It allows to use the Device methods that change the
+// serial port parameters (SetBaud) or drive the modem control lines.
+func NewDeviceTransport(name string, t Transport) *Device {
+	return NewDevice(name, t, t)
+}
+
+// Transport returns the transport used by the device or nil if the device was
+// created by NewDevice with the reader that doesn't implement Transport.
+func (d *Device) Transport() Transport {
+	return d.t
+}
+
+// SetBaud changes the baudrate and the flow control of both the ESP-AT device
+// (AT+UART_CUR) and the transport. The device must be created with a
+// transport.
+func (d *Device) SetBaud(baud int, hwFlowControl bool) error {
+	const name = "+UART_CUR="
+	if d.t == nil {
+		return &Error{d.name, name, ErrNoTransport}
+	}
+	fc := 0
+	if hwFlowControl {
+		fc = 3
+	}
+	d.cmdx.Lock()
+	defer d.cmdx.Unlock()
+	if _, err := d.UnsafeCmd(name, baud, 8, 1, 0, fc); err != nil {
+		return err
+	}
+	if err := d.t.SetSpeed(baud); err != nil {
+		return &Error{d.name, name, err}
+	}
+	if err := d.t.SetFlowControl(hwFlowControl); err != nil {
+		return &Error{d.name, name, err}
+	}
+	return nil
+}
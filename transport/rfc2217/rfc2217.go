@@ -0,0 +1,257 @@
+// Package rfc2217 implements the RFC 2217 (Telnet Com Port Control Option)
+// client that can be used as espat.Transport to access the remote serial
+// ports served by ser2net and similar servers.
+package rfc2217
+
+import (
+	"bufio"
+	"net"
+	"sync"
+)
+
+// Telnet commands and options.
+const (
+	se   = 240
+	sb   = 250
+	will = 251
+	wont = 252
+	do   = 253
+	dont = 254
+	iac  = 255
+
+	optBinary  = 0
+	optSGA     = 3
+	optComPort = 44
+)
+
+// Com port option commands (client to server).
+const (
+	setBaudrate = 1
+	setDatasize = 2
+	setParity   = 3
+	setStopsize = 4
+	setControl  = 5
+)
+
+// Values of the SET-CONTROL command.
+const (
+	ctrlNoFlow = 1
+	ctrlHWFlow = 3
+	ctrlDTROn  = 8
+	ctrlDTROff = 9
+	ctrlRTSOn  = 11
+	ctrlRTSOff = 12
+)
+
+// Read states.
+const (
+	stData = iota
+	stIAC
+	stOpt
+	stSB
+	stSBIAC
+)
+
+// Port is a remote serial port accessed using RFC 2217.
+type Port struct {
+	conn net.Conn
+	r    *bufio.Reader
+	wmx  sync.Mutex
+
+	state int
+	cmd   byte
+}
+
+// Dial connects to the RFC 2217 server at the address (host:port) and
+// configures the port to 8N1 and the given baudrate.
+func Dial(address string, baud int) (*Port, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	p := &Port{conn: conn, r: bufio.NewReader(conn)}
+	_, err = conn.Write([]byte{
+		iac, will, optComPort,
+		iac, will, optBinary, iac, do, optBinary,
+		iac, will, optSGA, iac, do, optSGA,
+	})
+	if err == nil {
+		err = p.subneg(setDatasize, 8)
+	}
+	if err == nil {
+		err = p.subneg(setParity, 1) // none
+	}
+	if err == nil {
+		err = p.subneg(setStopsize, 1)
+	}
+	if err == nil {
+		err = p.SetSpeed(baud)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close closes the connection.
+func (p *Port) Close() error {
+	return p.conn.Close()
+}
+
+// Read implements the io.Reader interface. It removes the Telnet commands
+// from the received data.
+func (p *Port) Read(b []byte) (n int, err error) {
+	for n == 0 && len(b) != 0 {
+		var c byte
+		if c, err = p.r.ReadByte(); err != nil {
+			return
+		}
+		switch p.state {
+		case stData:
+			if c == iac {
+				p.state = stIAC
+				continue
+			}
+			b[n] = c
+			n++
+			// read all buffered data at once
+			for n < len(b) && p.r.Buffered() != 0 {
+				if c, _ = p.r.ReadByte(); c == iac {
+					p.state = stIAC
+					break
+				}
+				b[n] = c
+				n++
+			}
+		case stIAC:
+			p.state = stData
+			switch c {
+			case iac:
+				b[n] = c // escaped 0xFF
+				n++
+			case will, wont, do, dont:
+				p.cmd = c
+				p.state = stOpt
+			case sb:
+				p.state = stSB
+			}
+		case stOpt:
+			p.state = stData
+			if err = p.negotiate(p.cmd, c); err != nil {
+				return
+			}
+		case stSB:
+			// ignore the server notifications
+			if c == iac {
+				p.state = stSBIAC
+			}
+		case stSBIAC:
+			p.state = stSB
+			if c == se {
+				p.state = stData
+			}
+		}
+	}
+	return
+}
+
+// negotiate refuses all options not requested by Dial.
+func (p *Port) negotiate(cmd, opt byte) error {
+	var reply byte
+	switch opt {
+	case optBinary, optSGA, optComPort:
+		return nil // already requested
+	}
+	switch cmd {
+	case do:
+		reply = wont
+	case will:
+		reply = dont
+	default:
+		return nil
+	}
+	p.wmx.Lock()
+	_, err := p.conn.Write([]byte{iac, reply, opt})
+	p.wmx.Unlock()
+	return err
+}
+
+// Write implements the io.Writer interface.
+func (p *Port) Write(b []byte) (int, error) {
+	p.wmx.Lock()
+	defer p.wmx.Unlock()
+	n := 0
+	for len(b) != 0 {
+		i := 0
+		for i < len(b) && b[i] != iac {
+			i++
+		}
+		if i < len(b) {
+			i++ // include IAC, it will be written twice
+		}
+		m, err := p.conn.Write(b[:i])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if b[i-1] == iac {
+			if _, err = p.conn.Write([]byte{iac}); err != nil {
+				return n, err
+			}
+		}
+		b = b[i:]
+	}
+	return n, nil
+}
+
+// subneg sends the com port option subnegotiation.
+func (p *Port) subneg(cmd byte, val ...byte) error {
+	buf := make([]byte, 0, 6+2*len(val))
+	buf = append(buf, iac, sb, optComPort, cmd)
+	for _, c := range val {
+		buf = append(buf, c)
+		if c == iac {
+			buf = append(buf, c)
+		}
+	}
+	buf = append(buf, iac, se)
+	p.wmx.Lock()
+	_, err := p.conn.Write(buf)
+	p.wmx.Unlock()
+	return err
+}
+
+// SetSpeed implements the espat.Transport SetSpeed method.
+func (p *Port) SetSpeed(baud int) error {
+	return p.subneg(
+		setBaudrate, byte(baud>>24), byte(baud>>16), byte(baud>>8), byte(baud),
+	)
+}
+
+// SetFlowControl implements the espat.Transport SetFlowControl method.
+func (p *Port) SetFlowControl(hw bool) error {
+	v := byte(ctrlNoFlow)
+	if hw {
+		v = ctrlHWFlow
+	}
+	return p.subneg(setControl, v)
+}
+
+// SetDTR implements the espat.Transport SetDTR method.
+func (p *Port) SetDTR(on bool) error {
+	v := byte(ctrlDTROff)
+	if on {
+		v = ctrlDTROn
+	}
+	return p.subneg(setControl, v)
+}
+
+// SetRTS implements the espat.Transport SetRTS method.
+func (p *Port) SetRTS(on bool) error {
+	v := byte(ctrlRTSOff)
+	if on {
+		v = ctrlRTSOn
+	}
+	return p.subneg(setControl, v)
+}
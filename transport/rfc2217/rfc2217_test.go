@@ -0,0 +1,41 @@
+package rfc2217
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	c, s := net.Pipe()
+	p := &Port{conn: c, r: bufio.NewReader(c)}
+	go func() {
+		s.Write([]byte{
+			'a', iac, iac, iac, sb, optComPort, 107, 1, iac, se, 'b',
+			iac, do, optBinary, 'c',
+		})
+		s.Close()
+	}()
+	data, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\xffbc"; string(data) != want {
+		t.Errorf("read %q != %q", data, want)
+	}
+
+	c, s = net.Pipe()
+	p = &Port{conn: c, r: bufio.NewReader(c)}
+	go func() {
+		p.Write([]byte{'x', iac, 'y'})
+		c.Close()
+	}()
+	data, err = io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "x\xff\xffy"; string(data) != want {
+		t.Errorf("written %q != %q", data, want)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+// Package uart implements the espat.Transport interface for the local serial
+// ports using the github.com/ziutek/serial package.
+package uart
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/ziutek/serial"
+)
+
+// Port is a local serial port.
+type Port struct {
+	*serial.Serial
+}
+
+// Open opens the serial port (e.g. /dev/ttyUSB0) and sets its speed.
+func Open(name string, baud int) (*Port, error) {
+	s, err := serial.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.SetSpeed(baud); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return &Port{s}, nil
+}
+
+// SetFlowControl implements the espat.Transport SetFlowControl method.
+func (p *Port) SetFlowControl(hw bool) error {
+	return p.SetFlowCtrl(hw, false)
+}
+
+// SetDTR implements the espat.Transport SetDTR method.
+func (p *Port) SetDTR(on bool) error {
+	return p.setModemLine(syscall.TIOCM_DTR, on)
+}
+
+// SetRTS implements the espat.Transport SetRTS method.
+func (p *Port) SetRTS(on bool) error {
+	return p.setModemLine(syscall.TIOCM_RTS, on)
+}
+
+func (p *Port) setModemLine(bit int, on bool) error {
+	bits := int32(bit)
+	req := uintptr(syscall.TIOCMBIC)
+	if on {
+		req = syscall.TIOCMBIS
+	}
+	_, _, e := syscall.Syscall(
+		syscall.SYS_IOCTL, p.File().Fd(), req, uintptr(unsafe.Pointer(&bits)),
+	)
+	if e != 0 {
+		return e
+	}
+	return nil
+}